@@ -0,0 +1,153 @@
+package unifiexporter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// An AlarmCollector is a Prometheus collector for metrics regarding Ubiquiti
+// UniFi alarms.
+type AlarmCollector struct {
+	Alarms      *prometheus.Desc
+	AlarmsTotal *prometheus.Desc
+
+	c     *unifi.Client
+	sites []*unifi.Site
+
+	endpoints *endpointTracker
+	timeout   time.Duration
+	logger    *errorLogger
+}
+
+// Verify that the Exporter implements the collector interface.
+var _ collector = &AlarmCollector{}
+
+// NewAlarmCollector creates a new AlarmCollector which collects metrics for
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewAlarmCollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *AlarmCollector {
+	const (
+		subsystem = "alarms"
+	)
+
+	var (
+		labelsSiteOnly = []string{"site"}
+		labelsAlarm    = []string{"site", "subsystem", "archived", "severity"}
+	)
+
+	return &AlarmCollector{
+		Alarms: prometheus.NewDesc(
+			// Subsystem is used as name so we get "unifi_alarms"
+			prometheus.BuildFQName(namespace, "", subsystem),
+			"Number of alarms, grouped by subsystem, archived state, and severity",
+			labelsAlarm,
+			nil,
+		),
+
+		AlarmsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "total"),
+			"Total number of alarms, including archived alarms",
+			labelsSiteOnly,
+			nil,
+		),
+
+		c:     c,
+		sites: sites,
+
+		endpoints: cfg.endpointTracker(),
+		timeout:   cfg.orDefault().ScrapeTimeout,
+		logger:    cfg.orDefault().logger,
+	}
+}
+
+// collect begins a metrics collection task for all metrics related to UniFi
+// alarms.
+func (c *AlarmCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	return forEachSite(c.sites, 1, func(s *unifi.Site) (*prometheus.Desc, error) {
+		alarms, err := c.c.AlarmsContext(ctx, s.Name)
+		if err != nil {
+			return c.Alarms, &siteError{site: s, err: err}
+		}
+		c.endpoints.success(endpointAlarms)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.AlarmsTotal,
+			prometheus.GaugeValue,
+			float64(len(alarms)),
+			s.Description,
+		)
+
+		c.collectAlarmCounts(ch, s.Description, alarms)
+		return nil, nil
+	})
+}
+
+// An alarmGroup is the set of labels by which alarms are counted.
+type alarmGroup struct {
+	subsystem string
+	archived  bool
+	severity  string
+}
+
+// collectAlarmCounts collects the number of alarms in each subsystem,
+// archived state, and severity, so that active alarms can be alerted upon
+// separately from those which have been archived.
+func (c *AlarmCollector) collectAlarmCounts(ch chan<- prometheus.Metric, siteLabel string, alarms []*unifi.Alarm) {
+	groups := make(map[alarmGroup]int)
+	for _, a := range alarms {
+		groups[alarmGroup{
+			subsystem: a.Subsystem,
+			archived:  a.Archived,
+			severity:  a.Severity,
+		}]++
+	}
+
+	for g, n := range groups {
+		ch <- prometheus.MustNewConstMetric(
+			c.Alarms,
+			prometheus.GaugeValue,
+			float64(n),
+			siteLabel,
+			g.subsystem,
+			strconv.FormatBool(g.archived),
+			g.severity,
+		)
+	}
+}
+
+// Describe sends the descriptors of each metric over to the provided channel.
+// The corresponding metric values are sent separately.
+func (c *AlarmCollector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.Alarms,
+		c.AlarmsTotal,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect is the same as CollectError, but ignores any errors which occur.
+// Collect exists to satisfy the prometheus.Collector interface.
+func (c *AlarmCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.CollectError(ch)
+}
+
+// CollectError sends the metric values for each metric pertaining to UniFi
+// alarms over to the provided prometheus Metric channel, returning any errors
+// which occur.
+func (c *AlarmCollector) CollectError(ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		c.logger.Printf("[ERROR] failed collecting alarm metric %v: %v", desc, err)
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package unifiexporter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/unifi"
+)
+
+func TestAlarmCollector(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		input   string
+		sites   []*unifi.Site
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "no alarms, one site",
+			input: strings.TrimSpace(`
+{
+	"data": []
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_alarms_total{site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "active and archived alarms, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"ap": "de:ad:be:ef:de:ad",
+			"ap_name": "AP",
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_AP_Lost_Contact",
+			"msg": "AP was disconnected",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "def",
+			"ap": "de:ad:be:ef:de:ad",
+			"ap_name": "AP",
+			"archived": true,
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_AP_Lost_Contact",
+			"msg": "AP was disconnected",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "ghi",
+			"ap": "ab:ad:1d:ea:ab:ad",
+			"ap_name": "AP2",
+			"archived": true,
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_AP_Lost_Contact",
+			"msg": "AP2 was disconnected",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "jkl",
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_GW_WANTransition",
+			"msg": "Gateway WAN transition",
+			"subsystem": "www"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_alarms{archived="false",severity="",site="Default",subsystem="wlan"} 1`),
+				regexp.MustCompile(`unifi_alarms{archived="true",severity="",site="Default",subsystem="wlan"} 2`),
+				regexp.MustCompile(`unifi_alarms{archived="false",severity="",site="Default",subsystem="www"} 1`),
+				regexp.MustCompile(`unifi_alarms_total{site="Default"} 4`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "alarms with distinct severities, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"ap": "de:ad:be:ef:de:ad",
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_AP_Lost_Contact",
+			"severity": "critical",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "def",
+			"ap": "ab:ad:1d:ea:ab:ad",
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_AP_Lost_Contact",
+			"severity": "critical",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "ghi",
+			"ap": "de:ad:be:ef:de:ad",
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_AP_ChannelChanged",
+			"severity": "info",
+			"subsystem": "wlan"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_alarms{archived="false",severity="critical",site="Default",subsystem="wlan"} 2`),
+				regexp.MustCompile(`unifi_alarms{archived="false",severity="info",site="Default",subsystem="wlan"} 1`),
+				regexp.MustCompile(`unifi_alarms_total{site="Default"} 3`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testAlarmCollector(t, []byte(tt.input), tt.sites)
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex")
+			}
+		}
+	}
+}
+
+func testAlarmCollector(t *testing.T, input []byte, sites []*unifi.Site) []byte {
+	c, done := testUniFiClient(t, input)
+	defer done()
+
+	collector := NewAlarmCollector(
+		c,
+		sites,
+		nil,
+	)
+
+	return testCollector(t, collector)
+}
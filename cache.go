@@ -0,0 +1,173 @@
+package unifiexporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/unifi"
+)
+
+// A responseCache caches UniFi Controller API responses for each site, so that
+// frequent collections do not repeatedly query the UniFi Controller.
+//
+// A nil *responseCache caches nothing.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[cacheKey]cacheEntry
+
+	// gen identifies the current collection, and hit reports whether any
+	// response retrieved during a previous collection was served during
+	// it.  Both are advanced by served.
+	gen uint64
+	hit bool
+
+	// now is used to determine when cache entries expire.
+	now func() time.Time
+}
+
+// A cacheKey identifies a cached API response for a site.
+type cacheKey struct {
+	site     string
+	endpoint string
+}
+
+// A cacheEntry is a cached API response, the time at which it expires, and
+// the collection during which it was retrieved.
+type cacheEntry struct {
+	v       interface{}
+	expires time.Time
+	gen     uint64
+}
+
+// newResponseCache creates a responseCache which caches API responses for
+// ttl.
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[cacheKey]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// devices returns the devices for site, retrieving them using c and ctx if they
+// are not cached.
+func (rc *responseCache) devices(ctx context.Context, c *unifi.Client, site string) ([]*unifi.Device, error) {
+	v, err := rc.get(site, endpointDevices, func() (interface{}, error) {
+		return c.DevicesContext(ctx, site)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*unifi.Device), nil
+}
+
+// stations returns the stations for site, retrieving them using c and ctx if
+// they are not cached.
+func (rc *responseCache) stations(ctx context.Context, c *unifi.Client, site string) ([]*unifi.Station, error) {
+	v, err := rc.get(site, endpointStations, func() (interface{}, error) {
+		return c.StationsContext(ctx, site)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*unifi.Station), nil
+}
+
+// knownClients returns the known clients for site, retrieving them using c
+// and ctx if they are not cached.
+func (rc *responseCache) knownClients(ctx context.Context, c *unifi.Client, site string) ([]*unifi.KnownClient, error) {
+	v, err := rc.get(site, endpointKnownClients, func() (interface{}, error) {
+		return c.KnownClientsContext(ctx, site)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*unifi.KnownClient), nil
+}
+
+// sites returns the sites managed by the UniFi Controller, retrieving them
+// using c and ctx if they are not cached.
+func (rc *responseCache) sites(ctx context.Context, c *unifi.Client) ([]*unifi.Site, error) {
+	v, err := rc.get("", endpointSites, func() (interface{}, error) {
+		return c.SitesContext(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*unifi.Site), nil
+}
+
+// get returns the cached response for site and endpoint, or invokes fn to
+// retrieve and cache it if no unexpired response is cached.  Errors returned
+// by fn are not cached.
+func (rc *responseCache) get(site, endpoint string, fn func() (interface{}, error)) (interface{}, error) {
+	if rc == nil {
+		return fn()
+	}
+
+	k := cacheKey{site: site, endpoint: endpoint}
+
+	rc.mu.Lock()
+	e, ok := rc.entries[k]
+	// Responses are always shared by collectors within a collection, and
+	// are otherwise reused until they expire
+	if ok && (e.gen == rc.gen || rc.now().Before(e.expires)) {
+		if e.gen != rc.gen {
+			rc.hit = true
+		}
+		rc.mu.Unlock()
+		return e.v, nil
+	}
+	rc.mu.Unlock()
+
+	v, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.entries[k] = cacheEntry{
+		v:       v,
+		expires: rc.now().Add(rc.ttl),
+		gen:     rc.gen,
+	}
+
+	return v, nil
+}
+
+// served reports whether any response retrieved during a previous collection
+// was served during the current collection, and then begins a new collection.
+func (rc *responseCache) served() bool {
+	if rc == nil {
+		return false
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	hit := rc.hit
+	rc.hit = false
+	rc.gen++
+
+	return hit
+}
+
+// reset discards all cached responses.
+func (rc *responseCache) reset() {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.entries = make(map[cacheKey]cacheEntry)
+}
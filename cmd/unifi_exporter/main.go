@@ -3,13 +3,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mdlayher/unifi"
@@ -21,127 +33,749 @@ import (
 type Config struct {
 	Listen map[string]string `yaml:"listen"`
 	Unifi  map[string]string `yaml:"unifi"`
+	Push   map[string]string `yaml:"push"`
 }
 
 const (
 	// userAgent is ther user agent reported to the UniFi Controller API.
 	userAgent = "github.com/mdlayher/unifi_exporter"
+
+	// shutdownTimeout is the maximum time to wait for in-flight requests to
+	// complete when the exporter is shut down.
+	shutdownTimeout = 10 * time.Second
 )
 
 func main() {
+	start := time.Now()
+
 	var configFile = flag.String("config.file", "", "Relative path to config file yaml")
 	flag.Parse()
 
-	var config Config
-	source, err := ioutil.ReadFile(*configFile)
-	if err != nil {
-		log.Fatalf("failed to read config file %q: %v", *configFile, err)
-	}
-	err = yaml.Unmarshal(source, &config)
+	config, err := readConfig(*configFile)
 	if err != nil {
-		log.Fatalf("failed to read YAML from config file %q: %v", *configFile, err)
+		log.Fatal(err)
 	}
 
 	listenAddr := config.Listen["address"]
 	metricsPath := config.Listen["metricspath"]
+
+	if listenAddr == "" {
+		// Set default port to 9130 if left blank in config.yml
+		listenAddr = ":9130"
+	}
+	if metricsPath == "" {
+		metricsPath = "/metrics"
+	}
+
+	redirect := true
+	if dr, ok := config.Listen["disable_root_redirect"]; ok {
+		disable, err := strconv.ParseBool(dr)
+		if err != nil {
+			log.Fatalf("failed to parse bool %s: %v", dr, err)
+		}
+		redirect = !disable
+	}
+
+	var errorOnFailure bool
+	if ef, ok := config.Listen["error_on_failure"]; ok {
+		errorOnFailure, err = strconv.ParseBool(ef)
+		if err != nil {
+			log.Fatalf("failed to parse bool %s: %v", ef, err)
+		}
+	}
+
+	useSites, clientFn, err := setup(config)
+	if err != nil {
+		log.Fatalf("failed to configure UniFi client from config file %q: %v", *configFile, err)
+	}
+
+	cfg, err := exporterConfig(config)
+	if err != nil {
+		log.Fatalf("failed to configure exporter from config file %q: %v", *configFile, err)
+	}
+
+	e, err := unifiexporter.New(useSites, clientFn, cfg)
+	if err != nil {
+		log.Fatalf("failed to create exporter: %v", err)
+	}
+
+	prometheus.MustRegister(e, startTimeCollector(start), insecurePublic)
+
+	go reloadOnSignal(e, *configFile)
+
+	pcfg, err := parsePushConfig(config)
+	if err != nil {
+		log.Fatalf("failed to configure push from config file %q: %v", *configFile, err)
+	}
+	if pcfg != nil {
+		log.Printf("Pushing UniFi metrics to %q every %s for site(s): %s", pcfg.Address, pcfg.Interval, sitesString(useSites))
+
+		// Push until the process exits
+		pushLoop(pcfg, prometheus.DefaultGatherer, nil)
+		return
+	}
+
+	h := newHandler(
+		metricsPath,
+		redirect,
+		failureHandler(errorOnFailure, e.Err, prometheus.Handler()),
+		refreshHandler(config.Listen["refresh_token"], e.Refresh),
+	)
+
+	log.Printf("Starting UniFi exporter on %q for site(s): %s", listenAddr, sitesString(useSites))
+
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		log.Fatalf("cannot start UniFi exporter: %s", err)
+	}
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM)
+
+	if err := serve(l, h, sigC, shutdownTimeout); err != nil {
+		log.Fatalf("failed to serve UniFi exporter: %s", err)
+	}
+
+	log.Println("[INFO] UniFi exporter shut down")
+}
+
+// serve serves HTTP requests on l using h until a signal is received on sigC,
+// and then shuts down gracefully, waiting up to timeout for in-flight requests
+// to complete.
+func serve(l net.Listener, h http.Handler, sigC <-chan os.Signal, timeout time.Duration) error {
+	srv := &http.Server{Handler: h}
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case sig := <-sigC:
+		log.Printf("[INFO] received %s, shutting down UniFi exporter", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	// Serve always returns an error once Shutdown is called
+	if err := <-errC; err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// newHandler returns an http.Handler which serves metrics using h on
+// metricsPath, and serves refresh on /refresh.  If redirect is true, requests
+// for any other path are redirected to metricsPath; otherwise they are not
+// found.
+func newHandler(metricsPath string, redirect bool, h, refresh http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, h)
+	mux.Handle("/refresh", refresh)
+
+	if redirect {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, metricsPath, http.StatusMovedPermanently)
+		})
+	}
+
+	return mux
+}
+
+// refreshHandler returns an http.Handler which invokes fn for each POST
+// request, so that the next collection bypasses cached responses.  If token is
+// not empty, requests must present it as a bearer token.
+func refreshHandler(token string, fn func()) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		fn()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// failureHandler returns an http.Handler which serves metrics using h.  If
+// enabled is true and errFn reports an error once metrics are gathered, a 503
+// Service Unavailable response with a short error body is served instead.  If
+// enabled is false, h is returned unmodified.
+func failureHandler(enabled bool, errFn func() error, h http.Handler) http.Handler {
+	if !enabled {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Buffer the response, since the outcome of the collection is only
+		// known once h has finished gathering metrics
+		bw := &bufferedResponseWriter{
+			header: make(http.Header),
+			code:   http.StatusOK,
+		}
+		h.ServeHTTP(bw, r)
+
+		if err := errFn(); err != nil {
+			http.Error(w, fmt.Sprintf("UniFi Controller is unreachable: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		for k, v := range bw.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(bw.code)
+		_, _ = w.Write(bw.body.Bytes())
+	})
+}
+
+// A bufferedResponseWriter is an http.ResponseWriter which buffers a response
+// so it can be inspected before being written.
+type bufferedResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// Write implements http.ResponseWriter.
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// startTimeCollector returns a prometheus.Collector which exposes start as
+// the time the exporter process started.
+func startTimeCollector(start time.Time) prometheus.Collector {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "unifi",
+		Subsystem: "exporter",
+		Name:      "start_time_seconds",
+		Help:      "Start time of the exporter process since the UNIX epoch in seconds",
+	})
+	g.Set(float64(start.UnixNano()) / float64(time.Second))
+
+	return g
+}
+
+// insecurePublic reports whether TLS verification is disabled for a UniFi
+// Controller with a public address.
+var insecurePublic = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "unifi",
+	Subsystem: "controller",
+	Name:      "insecure_public",
+	Help:      "Whether TLS verification is disabled for a UniFi Controller with a public address (1) or not (0)",
+})
+
+// privateNets are networks which are not reachable from the public internet.
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range []string{
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"100.64.0.0/10",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	} {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(fmt.Sprintf("failed to parse private network %q: %v", s, err))
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets
+}()
+
+// isPublicAddr determines if the host of the UniFi Controller address addr
+// is reachable from the public internet.  Host names are resolved using
+// lookup, and are considered public if any of their addresses are public.
+func isPublicAddr(addr string, lookup func(host string) ([]net.IP, error)) (bool, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return false, err
+	}
+
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return false, fmt.Errorf("no host in address %q", addr)
+	}
+	if host == "localhost" {
+		return false, nil
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ips, err = lookup(host)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for _, ip := range ips {
+		if !isPrivateIP(ip) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isPrivateIP determines if ip resides in a private network.
+func isPrivateIP(ip net.IP) bool {
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readConfig reads and parses the YAML configuration file at path.
+func readConfig(path string) (*Config, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %q: %v", path, err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(source, &config); err != nil {
+		return nil, fmt.Errorf("failed to read YAML from config file %q: %v", path, err)
+	}
+
+	applyEnv(&config, os.LookupEnv)
+
+	return &config, nil
+}
+
+// envOverrides maps environment variables to the keys in the UniFi section of
+// the configuration file which they override.
+var envOverrides = map[string]string{
+	"UNIFI_ADDRESS":  "address",
+	"UNIFI_USERNAME": "username",
+	"UNIFI_PASSWORD": "password",
+	"UNIFI_SITE":     "site",
+	"UNIFI_INSECURE": "insecure",
+	"UNIFI_TIMEOUT":  "timeout",
+}
+
+// applyEnv overrides values in the UniFi section of config with any
+// environment variables in envOverrides which are set, as reported by lookup.
+// Values are parsed later along with those from the configuration file.
+func applyEnv(config *Config, lookup func(key string) (string, bool)) {
+	for env, key := range envOverrides {
+		v, ok := lookup(env)
+		if !ok {
+			continue
+		}
+
+		if config.Unifi == nil {
+			config.Unifi = make(map[string]string)
+		}
+
+		config.Unifi[key] = v
+	}
+}
+
+// setup uses the UniFi section of config to authenticate to the UniFi
+// Controller, and returns the sites to be monitored and a
+// unifiexporter.ClientFunc for the exporter.
+func setup(config *Config) ([]*unifi.Site, unifiexporter.ClientFunc, error) {
 	unifiAddr := config.Unifi["address"]
 	username := config.Unifi["username"]
 	password := config.Unifi["password"]
 	site := config.Unifi["site"]
+	siteRegex := config.Unifi["site_regex"]
+
+	if unifiAddr == "" {
+		return nil, nil, errors.New("address of UniFi Controller API must be specified")
+	}
+	if username == "" {
+		return nil, nil, errors.New("username to authenticate to UniFi Controller API must be specified")
+	}
+	if password == "" {
+		return nil, nil, errors.New("password to authenticate to UniFi Controller API must be specified")
+	}
+
+	if site != "" && siteRegex != "" {
+		return nil, nil, errors.New("only one of site or site_regex may be specified")
+	}
+
+	var siteRE *regexp.Regexp
+	if siteRegex != "" {
+		var err error
+		siteRE, err = regexp.Compile(siteRegex)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse site regex %q: %v", siteRegex, err)
+		}
+	}
 
 	insecure := false
 	if ins, ok := config.Unifi["insecure"]; ok {
+		var err error
 		insecure, err = strconv.ParseBool(ins)
 		if err != nil {
-			log.Fatalf("failed to parse bool %s: %v", ins, err)
+			return nil, nil, fmt.Errorf("failed to parse bool %s: %v", ins, err)
+		}
+	}
+
+	var public bool
+	if insecure {
+		var err error
+		public, err = isPublicAddr(unifiAddr, net.LookupIP)
+		switch {
+		case err != nil:
+			log.Printf("[WARN] could not determine if UniFi Controller address %q is public: %v", unifiAddr, err)
+		case public:
+			log.Printf("[WARN] TLS verification is disabled for public UniFi Controller address %q", unifiAddr)
 		}
 	}
 
-	timeout := 5*time.Second
+	if public {
+		insecurePublic.Set(1)
+	} else {
+		insecurePublic.Set(0)
+	}
+
+	timeout := 5 * time.Second
 	if to, ok := config.Unifi["timeout"]; ok {
+		var err error
 		timeout, err = time.ParseDuration(to)
 		if err != nil {
-			log.Fatalf("failed to parse duration %q: %v", to, err)
+			return nil, nil, fmt.Errorf("failed to parse duration %q: %v", to, err)
 		}
 	}
 
-	if unifiAddr == "" {
-		log.Fatal("address of UniFi Controller API must be specified within config file: ", *configFile)
-	}
-	if username == "" {
-		log.Fatal("username to authenticate to UniFi Controller API must be specified within config file: ", *configFile)
-	}
-	if password == "" {
-		log.Fatal("password to authenticate to UniFi Controller API must be specified within config file: ", *configFile)
-	}
-	if listenAddr == "" {
-		// Set default port to 9130 if left blank in config.yml
-		listenAddr = ":9130"
+	var maxResponseSize int64
+	if ms, ok := config.Unifi["max_response_size"]; ok && ms != "" {
+		var err error
+		maxResponseSize, err = strconv.ParseInt(ms, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse max response size %q: %v", ms, err)
+		}
+		if maxResponseSize <= 0 {
+			return nil, nil, fmt.Errorf("max response size must be positive: %d", maxResponseSize)
+		}
 	}
-	if metricsPath == "" {
-		metricsPath = "/metrics"
+
+	tlsConfig, err := newTLSConfig(
+		insecure,
+		config.Unifi["ca_file"],
+		config.Unifi["tls_cert_file"],
+		config.Unifi["tls_key_file"],
+	)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	clientFn := newClient(
 		unifiAddr,
 		username,
 		password,
-		insecure,
+		tlsConfig,
 		timeout,
+		maxResponseSize,
 	)
 	c, err := clientFn()
 	if err != nil {
-		log.Fatalf("failed to create client: %v", err)
+		return nil, nil, fmt.Errorf("failed to create client: %v", err)
 	}
 
 	sites, err := c.Sites()
 	if err != nil {
-		log.Fatalf("failed to retrieve list of sites: %v", err)
+		return nil, nil, fmt.Errorf("failed to retrieve list of sites: %v", err)
 	}
 
-	useSites, err := pickSites(site, sites)
+	useSites, err := pickSites(site, siteRE, sites)
 	if err != nil {
-		log.Fatalf("failed to select a site: %v", err)
+		return nil, nil, fmt.Errorf("failed to select a site: %v", err)
+	}
+
+	return useSites, clientFn, nil
+}
+
+// exporterConfig uses the UniFi section of config to produce a
+// unifiexporter.Config which controls the behavior of the exporter.
+func exporterConfig(config *Config) (*unifiexporter.Config, error) {
+	var cfg unifiexporter.Config
+
+	if ts, ok := config.Unifi["timestamps"]; ok {
+		var err error
+		cfg.Timestamps, err = strconv.ParseBool(ts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bool %s: %v", ts, err)
+		}
 	}
 
-	e, err := unifiexporter.New(useSites, clientFn)
+	if sh, ok := config.Unifi["shard_by_site"]; ok {
+		var err error
+		cfg.ShardBySite, err = strconv.ParseBool(sh)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bool %s: %v", sh, err)
+		}
+	}
+
+	if mu, ok := config.Unifi["monotonic_uptime"]; ok {
+		var err error
+		cfg.MonotonicUptime, err = strconv.ParseBool(mu)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bool %s: %v", mu, err)
+		}
+	}
+
+	if mc, ok := config.Unifi["monotonic_counters"]; ok {
+		var err error
+		cfg.MonotonicCounters, err = strconv.ParseBool(mc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bool %s: %v", mc, err)
+		}
+	}
+
+	sat, okSat := config.Unifi["experience_satisfaction"]
+	rssi, okRSSI := config.Unifi["experience_rssi"]
+	if okSat || okRSSI {
+		t := unifiexporter.DefaultExperienceThresholds
+
+		var err error
+		if okSat {
+			t.SatisfactionFair, t.SatisfactionGood, err = parseThresholds(sat)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if okRSSI {
+			t.RSSIFair, t.RSSIGood, err = parseThresholds(rssi)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		cfg.ExperienceThresholds = &t
+	}
+
+	cfg.StationLabel = config.Unifi["station_label"]
+	cfg.Controller = config.Unifi["controller"]
+
+	if so, ok := config.Unifi["station_oui_label"]; ok {
+		var err error
+		cfg.StationOUILabel, err = strconv.ParseBool(so)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bool %s: %v", so, err)
+		}
+	}
+
+	if re, ok := config.Unifi["firmware_beta_regex"]; ok && re != "" {
+		var err error
+		cfg.BetaFirmware, err = regexp.Compile(re)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse firmware beta regex %q: %v", re, err)
+		}
+	}
+
+	if iv, ok := config.Unifi["error_log_interval"]; ok {
+		var err error
+		cfg.ErrorLogInterval, err = time.ParseDuration(iv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration %q: %v", iv, err)
+		}
+	}
+
+	if sw, ok := config.Unifi["satisfaction_window"]; ok {
+		var err error
+		cfg.SatisfactionWindow, err = strconv.Atoi(sw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse satisfaction window %q: %v", sw, err)
+		}
+	}
+
+	if mw, ok := config.Unifi["memory_trend_window"]; ok {
+		var err error
+		cfg.MemoryTrendWindow, err = strconv.Atoi(mw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse memory trend window %q: %v", mw, err)
+		}
+	}
+
+	if sc, ok := config.Unifi["site_concurrency"]; ok {
+		var err error
+		cfg.SiteConcurrency, err = strconv.Atoi(sc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse site concurrency %q: %v", sc, err)
+		}
+	}
+
+	if ct, ok := config.Unifi["cache_ttl"]; ok {
+		var err error
+		cfg.CacheTTL, err = time.ParseDuration(ct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration %q: %v", ct, err)
+		}
+	}
+
+	if st, ok := config.Unifi["scrape_timeout"]; ok {
+		var err error
+		cfg.ScrapeTimeout, err = time.ParseDuration(st)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration %q: %v", st, err)
+		}
+	}
+
+	if wt, ok := config.Unifi["wireless_metrics_device_types"]; ok && wt != "" {
+		for _, t := range strings.Split(wt, ",") {
+			cfg.WirelessDeviceTypes = append(cfg.WirelessDeviceTypes, strings.TrimSpace(t))
+		}
+	}
+
+	if it, ok := config.Unifi["idle_threshold"]; ok {
+		var err error
+		cfg.IdleThreshold, err = time.ParseDuration(it)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration %q: %v", it, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// parseThresholds parses a pair of experience thresholds in the form
+// "fair,good".
+func parseThresholds(s string) (int, int, error) {
+	ss := strings.Split(s, ",")
+	if len(ss) != 2 {
+		return 0, 0, fmt.Errorf("experience thresholds %q must be in the form \"fair,good\"", s)
+	}
+
+	fair, err := strconv.Atoi(strings.TrimSpace(ss[0]))
 	if err != nil {
-		log.Fatalf("failed to create exporter: %v", err)
+		return 0, 0, fmt.Errorf("failed to parse fair threshold %q: %v", ss[0], err)
+	}
+
+	good, err := strconv.Atoi(strings.TrimSpace(ss[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse good threshold %q: %v", ss[1], err)
 	}
 
-	prometheus.MustRegister(e)
+	if fair > good {
+		return 0, 0, fmt.Errorf("fair threshold %d must not exceed good threshold %d", fair, good)
+	}
 
-	http.Handle(metricsPath, prometheus.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, metricsPath, http.StatusMovedPermanently)
-	})
+	return fair, good, nil
+}
 
-	log.Printf("Starting UniFi exporter on %q for site(s): %s", listenAddr, sitesString(useSites))
+// reloadOnSignal reloads the exporter's sites and UniFi client from the
+// configuration file at path each time the process receives SIGHUP.  Only the
+// options used by setup are reloaded; changes to any other option require a
+// restart.
+func reloadOnSignal(e *unifiexporter.Exporter, path string) {
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGHUP)
 
-	if err := http.ListenAndServe(listenAddr, nil); err != nil {
-		log.Fatalf("cannot start UniFi exporter: %s", err)
+	for range sigC {
+		err := e.Reload(func() ([]*unifi.Site, unifiexporter.ClientFunc, error) {
+			config, err := readConfig(path)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return setup(config)
+		})
+		if err != nil {
+			log.Printf("[ERROR] failed to reload config file %q: %v", path, err)
+			continue
+		}
+
+		log.Printf("[INFO] reloaded config file %q", path)
 	}
 }
 
-// pickSites attempts to find a site with a description matching the value
-// specified in choose.  If choose is empty, all sites are returned.
-func pickSites(choose string, sites []*unifi.Site) ([]*unifi.Site, error) {
+// pickSites attempts to find sites with a description or name matching each
+// value in the comma-separated list specified in choose.  If re is not nil, all
+// sites with a description matching re are returned instead.  If choose is
+// empty and re is nil, all sites are returned.
+func pickSites(choose string, re *regexp.Regexp, sites []*unifi.Site) ([]*unifi.Site, error) {
+	if re != nil {
+		var pick []*unifi.Site
+		for _, s := range sites {
+			if re.MatchString(s.Description) {
+				pick = append(pick, s)
+			}
+		}
+		if len(pick) == 0 {
+			return nil, fmt.Errorf("no sites with description matching %q were found in UniFi Controller", re)
+		}
+
+		return pick, nil
+	}
+
 	if choose == "" {
 		return sites, nil
 	}
 
-	var pick *unifi.Site
+	var pick []*unifi.Site
+	for _, c := range strings.Split(choose, ",") {
+		c = strings.TrimSpace(c)
+
+		s := findSite(c, sites)
+		if s == nil {
+			return nil, fmt.Errorf("site with description or name %q was not found in UniFi Controller", c)
+		}
+
+		pick = append(pick, s)
+	}
+
+	return pick, nil
+}
+
+// findSite returns the site with a description matching choose, or if there
+// is none, the site with a name matching choose.  If no site matches, it
+// returns nil.
+func findSite(choose string, sites []*unifi.Site) *unifi.Site {
 	for _, s := range sites {
 		if s.Description == choose {
-			pick = s
-			break
+			return s
 		}
 	}
-	if pick == nil {
-		return nil, fmt.Errorf("site with description %q was not found in UniFi Controller", choose)
+
+	for _, s := range sites {
+		if s.Name == choose {
+			return s
+		}
 	}
 
-	return []*unifi.Site{pick}, nil
+	return nil
 }
 
 // sitesString returns a comma-separated string of site descriptions, meant
@@ -155,12 +789,61 @@ func sitesString(sites []*unifi.Site) string {
 	return strings.Join(ds, ", ")
 }
 
+// newTLSConfig returns a *tls.Config for connections to the UniFi Controller
+// which skips verification if insecure is true, verifies the controller using
+// the PEM encoded CA certificates in caFile if it is set, and presents the
+// client certificate in certFile and keyFile if they are set.  If no option is
+// used, newTLSConfig returns nil so the default configuration is used.
+func newTLSConfig(insecure bool, caFile, certFile, keyFile string) (*tls.Config, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("both tls_cert_file and tls_key_file must be specified")
+	}
+
+	if !insecure && caFile == "" && certFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+
+	if caFile != "" {
+		b, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in CA file: %q", caFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %v", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 // newClient returns a unifiexporter.ClientFunc using the input parameters.
-func newClient(addr, username, password string, insecure bool, timeout time.Duration) unifiexporter.ClientFunc {
+// If tlsConfig is nil, the default TLS configuration is used.  If
+// maxResponseSize is zero, the client's default maximum response size is used.
+func newClient(addr, username, password string, tlsConfig *tls.Config, timeout time.Duration, maxResponseSize int64) unifiexporter.ClientFunc {
 	return func() (*unifi.Client, error) {
 		httpClient := &http.Client{Timeout: timeout}
-		if insecure {
-			httpClient = unifi.InsecureHTTPClient(timeout)
+		if tlsConfig != nil {
+			httpClient.Transport = &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig.Clone(),
+			}
 		}
 
 		c, err := unifi.NewClient(addr, httpClient)
@@ -168,6 +851,9 @@ func newClient(addr, username, password string, insecure bool, timeout time.Dura
 			return nil, fmt.Errorf("cannot create UniFi Controller client: %v", err)
 		}
 		c.UserAgent = userAgent
+		if maxResponseSize > 0 {
+			c.MaxResponseSize = maxResponseSize
+		}
 
 		if err := c.Login(username, password); err != nil {
 			return nil, fmt.Errorf("failed to authenticate to UniFi Controller: %v", err)
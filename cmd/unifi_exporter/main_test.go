@@ -1,18 +1,37 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func Test_pickSites(t *testing.T) {
 	var tests = []struct {
 		desc   string
 		choose string
+		re     *regexp.Regexp
 		sites  []*unifi.Site
 		pick   []*unifi.Site
 		err    error
@@ -53,12 +72,79 @@ func Test_pickSites(t *testing.T) {
 			},
 			err: errors.New("was not found in UniFi Controller"),
 		},
+		{
+			desc:   "several valid sites chosen",
+			choose: "baz, foo",
+			sites: []*unifi.Site{
+				{Description: "foo"},
+				{Description: "bar"},
+				{Description: "baz"},
+			},
+			pick: []*unifi.Site{
+				{Description: "baz"},
+				{Description: "foo"},
+			},
+		},
+		{
+			desc:   "several sites chosen, one invalid",
+			choose: "foo,qux,bar",
+			sites: []*unifi.Site{
+				{Description: "foo"},
+				{Description: "bar"},
+				{Description: "baz"},
+			},
+			err: errors.New(`site with description or name "qux" was not found in UniFi Controller`),
+		},
+		{
+			desc:   "site chosen by name",
+			choose: "abcdef",
+			sites: []*unifi.Site{
+				{Name: "default", Description: "Default"},
+				{Name: "abcdef", Description: "Branch"},
+			},
+			pick: []*unifi.Site{
+				{Name: "abcdef", Description: "Branch"},
+			},
+		},
+		{
+			desc:   "description preferred over name",
+			choose: "branch",
+			sites: []*unifi.Site{
+				{Name: "branch", Description: "Old Branch"},
+				{Name: "abcdef", Description: "branch"},
+			},
+			pick: []*unifi.Site{
+				{Name: "abcdef", Description: "branch"},
+			},
+		},
+		{
+			desc: "regex matching several sites",
+			re:   regexp.MustCompile(`^Branch-`),
+			sites: []*unifi.Site{
+				{Description: "Branch-1"},
+				{Description: "HQ"},
+				{Description: "Branch-2"},
+			},
+			pick: []*unifi.Site{
+				{Description: "Branch-1"},
+				{Description: "Branch-2"},
+			},
+		},
+		{
+			desc: "regex matching no sites",
+			re:   regexp.MustCompile(`^Branch-`),
+			sites: []*unifi.Site{
+				{Description: "HQ"},
+				{Description: "Warehouse"},
+			},
+			err: errors.New("were found in UniFi Controller"),
+		},
 	}
 
 	for i, tt := range tests {
 		t.Logf("[%02d] test %q", i, tt.desc)
 
-		pick, err := pickSites(tt.choose, tt.sites)
+		pick, err := pickSites(tt.choose, tt.re, tt.sites)
 		if want, got := errStr(tt.err), errStr(err); !strings.Contains(got, want) {
 			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v",
 				want, got)
@@ -82,6 +168,86 @@ func errStr(err error) string {
 	return err.Error()
 }
 
+func Test_applyEnv(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		unifi  map[string]string
+		env    map[string]string
+		expect map[string]string
+	}{
+		{
+			desc: "no environment variables",
+			unifi: map[string]string{
+				"address":  "https://unifi:8443",
+				"password": "file",
+			},
+			expect: map[string]string{
+				"address":  "https://unifi:8443",
+				"password": "file",
+			},
+		},
+		{
+			desc: "environment overrides file",
+			unifi: map[string]string{
+				"address":  "https://unifi:8443",
+				"password": "file",
+				"timeout":  "5s",
+			},
+			env: map[string]string{
+				"UNIFI_PASSWORD": "env",
+				"UNIFI_INSECURE": "true",
+				"UNIFI_TIMEOUT":  "10s",
+			},
+			expect: map[string]string{
+				"address":  "https://unifi:8443",
+				"password": "env",
+				"insecure": "true",
+				"timeout":  "10s",
+			},
+		},
+		{
+			desc: "environment with no unifi section",
+			env: map[string]string{
+				"UNIFI_ADDRESS":  "https://unifi:8443",
+				"UNIFI_USERNAME": "admin",
+				"UNIFI_SITE":     "Default",
+			},
+			expect: map[string]string{
+				"address":  "https://unifi:8443",
+				"username": "admin",
+				"site":     "Default",
+			},
+		},
+		{
+			desc: "empty environment variable overrides file",
+			unifi: map[string]string{
+				"site": "Default",
+			},
+			env: map[string]string{
+				"UNIFI_SITE": "",
+			},
+			expect: map[string]string{
+				"site": "",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		config := &Config{Unifi: tt.unifi}
+		applyEnv(config, func(key string) (string, bool) {
+			v, ok := tt.env[key]
+			return v, ok
+		})
+
+		if want, got := tt.expect, config.Unifi; !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected unifi config:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+	}
+}
+
 func Test_sitesString(t *testing.T) {
 	var tests = []struct {
 		sites []*unifi.Site
@@ -116,3 +282,637 @@ func Test_sitesString(t *testing.T) {
 		}
 	}
 }
+
+func Test_parseThresholds(t *testing.T) {
+	var tests = []struct {
+		desc string
+		s    string
+		fair int
+		good int
+		err  error
+	}{
+		{
+			desc: "valid",
+			s:    "50,80",
+			fair: 50,
+			good: 80,
+		},
+		{
+			desc: "valid with spaces",
+			s:    "15, 25",
+			fair: 15,
+			good: 25,
+		},
+		{
+			desc: "one value",
+			s:    "50",
+			err:  errors.New("must be in the form"),
+		},
+		{
+			desc: "not a number",
+			s:    "foo,80",
+			err:  errors.New("failed to parse fair threshold"),
+		},
+		{
+			desc: "fair exceeds good",
+			s:    "80,50",
+			err:  errors.New("must not exceed good threshold"),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		fair, good, err := parseThresholds(tt.s)
+		if want, got := errStr(tt.err), errStr(err); !strings.Contains(got, want) {
+			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v", want, got)
+		}
+		if err != nil {
+			continue
+		}
+
+		if want, got := tt.fair, fair; want != got {
+			t.Fatalf("unexpected fair threshold:\n- want: %v\n-  got: %v", want, got)
+		}
+		if want, got := tt.good, good; want != got {
+			t.Fatalf("unexpected good threshold:\n- want: %v\n-  got: %v", want, got)
+		}
+	}
+}
+
+func Test_isPublicAddr(t *testing.T) {
+	lookup := func(host string) ([]net.IP, error) {
+		switch host {
+		case "unifi.internal":
+			return []net.IP{net.ParseIP("192.168.1.10")}, nil
+		case "unifi.example.com":
+			return []net.IP{net.ParseIP("192.168.1.10"), net.ParseIP("203.0.113.10")}, nil
+		}
+
+		return nil, errors.New("no such host")
+	}
+
+	var tests = []struct {
+		desc   string
+		addr   string
+		public bool
+		err    error
+	}{
+		{
+			desc: "localhost",
+			addr: "https://localhost:8443",
+		},
+		{
+			desc: "loopback IPv4",
+			addr: "https://127.0.0.1:8443",
+		},
+		{
+			desc: "private IPv4",
+			addr: "https://10.0.0.1:8443",
+		},
+		{
+			desc: "private IPv6",
+			addr: "https://[fd00::1]:8443",
+		},
+		{
+			desc:   "public IPv4",
+			addr:   "https://203.0.113.10:8443",
+			public: true,
+		},
+		{
+			desc:   "public IPv6 without port",
+			addr:   "https://[2001:db8::1]",
+			public: true,
+		},
+		{
+			desc: "host name with private address",
+			addr: "https://unifi.internal:8443",
+		},
+		{
+			desc:   "host name with public address",
+			addr:   "https://unifi.example.com:8443",
+			public: true,
+		},
+		{
+			desc: "unknown host name",
+			addr: "https://unifi.invalid:8443",
+			err:  errors.New("no such host"),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		public, err := isPublicAddr(tt.addr, lookup)
+		if want, got := errStr(tt.err), errStr(err); !strings.Contains(got, want) {
+			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v", want, got)
+		}
+		if err != nil {
+			continue
+		}
+
+		if want, got := tt.public, public; want != got {
+			t.Fatalf("unexpected public result:\n- want: %v\n-  got: %v", want, got)
+		}
+	}
+}
+
+func Test_startTimeCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(startTimeCollector(time.Now()))
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	if want, got := 1, len(mfs); want != got {
+		t.Fatalf("unexpected number of metric families:\n- want: %v\n-  got: %v",
+			want, got)
+	}
+
+	mf := mfs[0]
+	if want, got := "unifi_exporter_start_time_seconds", mf.GetName(); want != got {
+		t.Fatalf("unexpected metric name:\n- want: %v\n-  got: %v",
+			want, got)
+	}
+
+	start := mf.GetMetric()[0].GetGauge().GetValue()
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	if d := now - start; d < 0 || d > 5 {
+		t.Fatalf("start time is not close to now: %v", start)
+	}
+}
+
+func Test_newClientTLSClientCertificate(t *testing.T) {
+	certPEM, keyPEM, cert := testClientCertificate(t)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			t.Errorf("no client certificate presented")
+		}
+
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_, _ = w.Write([]byte(`{"meta":{"rc":"ok"},"data":[]}`))
+	}))
+	s.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}
+	s.StartTLS()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "unifi_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	// The test server's certificate is self-signed, so verification of the
+	// server is skipped in both cases
+	without, err := newTLSConfig(true, "", "", "")
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if _, err := newClient(s.URL, "user", "pass", without, time.Second, 0)(); err == nil {
+		t.Fatal("expected an error without a client certificate, but none occurred")
+	}
+
+	with, err := newTLSConfig(true, "", certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if _, err := newClient(s.URL, "user", "pass", with, time.Second, 0)(); err != nil {
+		t.Fatalf("failed to authenticate with a client certificate: %v", err)
+	}
+}
+
+func Test_newClientCAFile(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_, _ = w.Write([]byte(`{"meta":{"rc":"ok"},"data":[]}`))
+	}))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "unifi_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("failed to write CA certificate: %v", err)
+	}
+
+	// The test server's certificate is not trusted by default, but is
+	// trusted when its certificate is used as the CA
+	if _, err := newClient(s.URL, "user", "pass", nil, time.Second, 0)(); err == nil {
+		t.Fatal("expected an error without a CA file, but none occurred")
+	}
+
+	cfg, err := newTLSConfig(false, caFile, "", "")
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if cfg.InsecureSkipVerify {
+		t.Fatal("CA file unexpectedly disabled verification")
+	}
+	if _, err := newClient(s.URL, "user", "pass", cfg, time.Second, 0)(); err != nil {
+		t.Fatalf("failed to authenticate with a CA file: %v", err)
+	}
+}
+
+func Test_newTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unifi_exporter")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPEM, _, _ := testClientCertificate(t)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(caFile, certPEM, 0600); err != nil {
+		t.Fatalf("failed to write CA certificate: %v", err)
+	}
+
+	badCAFile := filepath.Join(dir, "bad.crt")
+	if err := ioutil.WriteFile(badCAFile, []byte("foo"), 0600); err != nil {
+		t.Fatalf("failed to write CA certificate: %v", err)
+	}
+
+	var tests = []struct {
+		desc     string
+		insecure bool
+		caFile   string
+		certFile string
+		keyFile  string
+		nilCfg   bool
+		rootCAs  bool
+		err      string
+	}{
+		{
+			desc:   "default",
+			nilCfg: true,
+		},
+		{
+			desc:     "insecure",
+			insecure: true,
+		},
+		{
+			desc:    "CA file",
+			caFile:  caFile,
+			rootCAs: true,
+		},
+		{
+			desc:   "CA file not found",
+			caFile: filepath.Join(dir, "missing.crt"),
+			err:    "failed to read CA file: open " + filepath.Join(dir, "missing.crt") + ": no such file or directory",
+		},
+		{
+			desc:   "CA file without certificates",
+			caFile: badCAFile,
+			err:    "no PEM encoded certificates found in CA file: \"" + badCAFile + "\"",
+		},
+		{
+			desc:     "certificate without key",
+			certFile: "client.crt",
+			err:      "both tls_cert_file and tls_key_file must be specified",
+		},
+		{
+			desc:    "key without certificate",
+			keyFile: "client.key",
+			err:     "both tls_cert_file and tls_key_file must be specified",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		cfg, err := newTLSConfig(tt.insecure, tt.caFile, tt.certFile, tt.keyFile)
+		if want, got := tt.err, errStr(err); want != got {
+			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+		if err != nil {
+			continue
+		}
+
+		if want, got := tt.nilCfg, cfg == nil; want != got {
+			t.Fatalf("unexpected nil config:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+		if cfg != nil && cfg.InsecureSkipVerify != tt.insecure {
+			t.Fatalf("unexpected InsecureSkipVerify: %v", cfg.InsecureSkipVerify)
+		}
+		if cfg != nil && (cfg.RootCAs != nil) != tt.rootCAs {
+			t.Fatalf("unexpected RootCAs: %v", cfg.RootCAs)
+		}
+	}
+}
+
+// testClientCertificate generates a self-signed TLS client certificate,
+// returning its PEM encoded certificate and key.
+func testClientCertificate(t *testing.T) ([]byte, []byte, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "unifi_exporter"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, cert
+}
+
+func Test_newHandler(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		redirect bool
+		path     string
+		code     int
+	}{
+		{
+			desc:     "metrics, redirect enabled",
+			redirect: true,
+			path:     "/metrics",
+			code:     http.StatusOK,
+		},
+		{
+			desc:     "root, redirect enabled",
+			redirect: true,
+			path:     "/",
+			code:     http.StatusMovedPermanently,
+		},
+		{
+			desc: "metrics, redirect disabled",
+			path: "/metrics",
+			code: http.StatusOK,
+		},
+		{
+			desc: "root, redirect disabled",
+			path: "/",
+			code: http.StatusNotFound,
+		},
+	}
+
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("unifi_up 1\n"))
+	})
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+		newHandler("/metrics", tt.redirect, metrics, http.NotFoundHandler()).ServeHTTP(w, r)
+
+		if want, got := tt.code, w.Code; want != got {
+			t.Fatalf("unexpected HTTP status code:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+		if tt.code == http.StatusMovedPermanently {
+			if want, got := "/metrics", w.Header().Get("Location"); want != got {
+				t.Fatalf("unexpected redirect location:\n- want: %v\n-  got: %v",
+					want, got)
+			}
+		}
+	}
+}
+
+func Test_refreshHandler(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		token   string
+		method  string
+		auth    string
+		code    int
+		refresh bool
+	}{
+		{
+			desc:    "no token",
+			method:  http.MethodPost,
+			code:    http.StatusAccepted,
+			refresh: true,
+		},
+		{
+			desc:   "bad method",
+			method: http.MethodGet,
+			code:   http.StatusMethodNotAllowed,
+		},
+		{
+			desc:    "token",
+			token:   "secret",
+			method:  http.MethodPost,
+			auth:    "Bearer secret",
+			code:    http.StatusAccepted,
+			refresh: true,
+		},
+		{
+			desc:   "missing token",
+			token:  "secret",
+			method: http.MethodPost,
+			code:   http.StatusUnauthorized,
+		},
+		{
+			desc:   "wrong token",
+			token:  "secret",
+			method: http.MethodPost,
+			auth:   "Bearer foo",
+			code:   http.StatusUnauthorized,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		var refreshed bool
+		h := refreshHandler(tt.token, func() { refreshed = true })
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, "/refresh", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+
+		newHandler("/metrics", false, http.NotFoundHandler(), h).ServeHTTP(w, r)
+
+		if want, got := tt.code, w.Code; want != got {
+			t.Fatalf("unexpected HTTP status code:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+		if want, got := tt.refresh, refreshed; want != got {
+			t.Fatalf("unexpected refresh:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+	}
+}
+
+func Test_failureHandler(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		enabled bool
+		err     error
+		code    int
+		body    string
+	}{
+		{
+			desc: "disabled, controller reachable",
+			code: http.StatusOK,
+			body: "unifi_up 1\n",
+		},
+		{
+			desc: "disabled, controller unreachable",
+			err:  errors.New("connection refused"),
+			code: http.StatusOK,
+			body: "unifi_up 1\n",
+		},
+		{
+			desc:    "enabled, controller reachable",
+			enabled: true,
+			code:    http.StatusOK,
+			body:    "unifi_up 1\n",
+		},
+		{
+			desc:    "enabled, controller unreachable",
+			enabled: true,
+			err:     errors.New("connection refused"),
+			code:    http.StatusServiceUnavailable,
+			body:    "UniFi Controller is unreachable: connection refused\n",
+		},
+	}
+
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("unifi_up 1\n"))
+	})
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+
+		errFn := func() error { return tt.err }
+		failureHandler(tt.enabled, errFn, metrics).ServeHTTP(w, r)
+
+		if want, got := tt.code, w.Code; want != got {
+			t.Fatalf("unexpected HTTP status code:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+		if want, got := tt.body, w.Body.String(); want != got {
+			t.Fatalf("unexpected HTTP body:\n- want: %q\n-  got: %q",
+				want, got)
+		}
+		if tt.code == http.StatusOK {
+			if want, got := "text/plain", w.Header().Get("Content-Type"); want != got {
+				t.Fatalf("unexpected content type:\n- want: %v\n-  got: %v",
+					want, got)
+			}
+		}
+	}
+}
+
+func Test_serveShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	// Block the request until the shutdown signal is sent, so that it is
+	// in-flight during shutdown
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("unifi_up 1\n"))
+	})
+
+	sigC := make(chan os.Signal, 1)
+	serveErrC := make(chan error, 1)
+	go func() {
+		serveErrC <- serve(l, h, sigC, 5*time.Second)
+	}()
+
+	type result struct {
+		res *http.Response
+		err error
+	}
+
+	resC := make(chan result, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/metrics")
+		resC <- result{res: res, err: err}
+	}()
+
+	<-started
+	sigC <- syscall.SIGTERM
+
+	// Give the server time to begin shutting down before the in-flight
+	// request completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	r := <-resC
+	if r.err != nil {
+		t.Fatalf("in-flight request failed: %v", r.err)
+	}
+	defer r.res.Body.Close()
+
+	if want, got := http.StatusOK, r.res.StatusCode; want != got {
+		t.Fatalf("unexpected HTTP status code:\n- want: %v\n-  got: %v",
+			want, got)
+	}
+
+	select {
+	case err := <-serveErrC:
+		if err != nil {
+			t.Fatalf("unexpected error from serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after shutdown signal")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// defaultPushInterval is the default interval between pushes to a
+	// Prometheus Pushgateway.
+	defaultPushInterval = 1 * time.Minute
+
+	// defaultPushJob is the default job label used when pushing to a
+	// Prometheus Pushgateway.
+	defaultPushJob = "unifi_exporter"
+)
+
+// A pushConfig configures pushing metrics to a Prometheus Pushgateway.
+type pushConfig struct {
+	Address  string
+	Interval time.Duration
+	Job      string
+	Instance string
+}
+
+// parsePushConfig uses the push section of config to produce a pushConfig.
+// If no Pushgateway address is configured, it returns nil, and metrics are
+// served over HTTP instead.
+func parsePushConfig(config *Config) (*pushConfig, error) {
+	addr := config.Push["address"]
+	if addr == "" {
+		return nil, nil
+	}
+
+	pcfg := &pushConfig{
+		Address:  strings.TrimSuffix(addr, "/"),
+		Interval: defaultPushInterval,
+		Job:      config.Push["job"],
+		Instance: config.Push["instance"],
+	}
+
+	if iv, ok := config.Push["interval"]; ok {
+		var err error
+		pcfg.Interval, err = time.ParseDuration(iv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration %q: %v", iv, err)
+		}
+		if pcfg.Interval <= 0 {
+			return nil, fmt.Errorf("push interval must be positive: %v", pcfg.Interval)
+		}
+	}
+
+	if pcfg.Job == "" {
+		pcfg.Job = defaultPushJob
+	}
+
+	if pcfg.Instance == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine instance for push: %v", err)
+		}
+
+		pcfg.Instance = host
+	}
+
+	return pcfg, nil
+}
+
+// pushLoop gathers metrics from g and pushes them to the Pushgateway
+// specified by pcfg once per interval, until done is closed.
+func pushLoop(pcfg *pushConfig, g prometheus.Gatherer, done <-chan struct{}) {
+	client := &http.Client{Timeout: pcfg.Interval}
+
+	tick := time.NewTicker(pcfg.Interval)
+	defer tick.Stop()
+
+	for {
+		if err := push(client, pcfg, g); err != nil {
+			log.Printf("[ERROR] failed to push metrics to %q: %v", pcfg.Address, err)
+		}
+
+		select {
+		case <-tick.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// push gathers metrics from g and pushes them to the Pushgateway specified
+// by pcfg, replacing any metrics previously pushed for its job and instance.
+//
+// The Pushgateway rejects samples with explicit timestamps, so any timestamps
+// are removed and samples are pushed as of the time of the push.
+func push(client *http.Client, pcfg *pushConfig, g prometheus.Gatherer) error {
+	mfs, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			m.TimestampMs = nil
+		}
+
+		if err := enc.Encode(mf); err != nil {
+			return fmt.Errorf("failed to encode metrics: %v", err)
+		}
+	}
+
+	u := fmt.Sprintf("%s/metrics/job/%s/instance/%s",
+		pcfg.Address,
+		url.PathEscape(pcfg.Job),
+		url.PathEscape(pcfg.Instance),
+	)
+
+	req, err := http.NewRequest("PUT", u, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtText))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if c := res.StatusCode; c != http.StatusAccepted && c != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected HTTP status code %d: %s", c, string(body))
+	}
+
+	return nil
+}
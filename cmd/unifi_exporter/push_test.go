@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func Test_parsePushConfig(t *testing.T) {
+	var tests = []struct {
+		desc string
+		push map[string]string
+		pcfg *pushConfig
+		err  error
+	}{
+		{
+			desc: "pull mode",
+		},
+		{
+			desc: "push mode",
+			push: map[string]string{
+				"address":  "http://pushgateway:9091/",
+				"interval": "30s",
+				"job":      "unifi",
+				"instance": "foo",
+			},
+			pcfg: &pushConfig{
+				Address:  "http://pushgateway:9091",
+				Interval: 30 * time.Second,
+				Job:      "unifi",
+				Instance: "foo",
+			},
+		},
+		{
+			desc: "push mode defaults",
+			push: map[string]string{
+				"address":  "http://pushgateway:9091",
+				"instance": "foo",
+			},
+			pcfg: &pushConfig{
+				Address:  "http://pushgateway:9091",
+				Interval: defaultPushInterval,
+				Job:      defaultPushJob,
+				Instance: "foo",
+			},
+		},
+		{
+			desc: "bad interval",
+			push: map[string]string{
+				"address":  "http://pushgateway:9091",
+				"interval": "0s",
+			},
+			err: errors.New("push interval must be positive"),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		pcfg, err := parsePushConfig(&Config{Push: tt.push})
+		if want, got := errStr(tt.err), errStr(err); !strings.Contains(got, want) {
+			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v", want, got)
+		}
+		if err != nil {
+			continue
+		}
+
+		if tt.pcfg == nil {
+			if pcfg != nil {
+				t.Fatalf("unexpected push config: %+v", pcfg)
+			}
+			continue
+		}
+
+		if want, got := *tt.pcfg, *pcfg; want != got {
+			t.Fatalf("unexpected push config:\n- want: %+v\n-  got: %+v", want, got)
+		}
+	}
+}
+
+func Test_push(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		body   string
+	}
+
+	reqC := make(chan request, 1)
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reqC <- request{
+			method: r.Method,
+			path:   r.URL.EscapedPath(),
+			body:   string(body),
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer pushgateway.Close()
+
+	// The Pushgateway rejects samples with timestamps, so the timestamp
+	// must not be pushed
+	ts := int64(1500000000000)
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{
+			Name: proto.String("unifi_test"),
+			Help: proto.String("Test metric"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{
+				Gauge:       &dto.Gauge{Value: proto.Float64(1)},
+				TimestampMs: &ts,
+			}},
+		}}, nil
+	})
+
+	// Job and instance are path segments, so they must be escaped as such
+	pcfg := &pushConfig{
+		Address:  pushgateway.URL,
+		Interval: time.Minute,
+		Job:      "unifi",
+		Instance: "foo bar/baz",
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go pushLoop(pcfg, g, done)
+
+	var req request
+	select {
+	case req = <-reqC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for push")
+	}
+
+	if want, got := "PUT", req.method; want != got {
+		t.Fatalf("unexpected HTTP method:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := "/metrics/job/unifi/instance/foo%20bar%2Fbaz", req.path; want != got {
+		t.Fatalf("unexpected URL path:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := "unifi_test 1\n", req.body; !strings.Contains(got, want) {
+		t.Fatalf("pushed metrics did not contain %q:\n%s", want, got)
+	}
+}
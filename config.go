@@ -0,0 +1,488 @@
+package unifiexporter
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mdlayher/unifi"
+)
+
+// A Config specifies optional behavior for an Exporter and its collectors.
+// A nil Config or the zero value of Config uses the default behavior.
+type Config struct {
+	// Timestamps specifies whether byte and packet counters for devices and
+	// stations are exposed with the time the UniFi Controller last saw the
+	// device or station, rather than the time of the scrape.
+	//
+	// Timestamped metrics are considered stale by Prometheus if the
+	// controller stops updating them, so this is disabled by default.
+	Timestamps bool
+
+	// ErrorLogInterval specifies the interval during which an identical
+	// error message will only be logged once.  If zero, a default interval
+	// of 5 minutes is used.
+	ErrorLogInterval time.Duration
+
+	// MonotonicCounters specifies whether byte and packet counters for
+	// devices and stations which decrease between scrapes, such as when a
+	// device reboots, should be treated as a counter reset.  The previous
+	// value is carried forward, so the exposed counter never decreases.
+	MonotonicCounters bool
+
+	// MonotonicUptime specifies whether device uptime, which decreases when
+	// a device reboots, should be accumulated across reboots so that the
+	// exposed uptime counter never decreases.
+	MonotonicUptime bool
+
+	// ExperienceThresholds specifies the thresholds used to group wireless
+	// stations into experience buckets.  If nil,
+	// DefaultExperienceThresholds is used.
+	ExperienceThresholds *ExperienceThresholds
+
+	// StationLabel specifies which station identifier is used as the sole
+	// identifying label for per-station metrics: one of StationLabelMAC,
+	// StationLabelHostname, or StationLabelID.  If a station does not report
+	// the chosen identifier, its MAC address is used instead.
+	//
+	// If empty, per-station metrics are labeled with all identifiers.
+	StationLabel string
+
+	// StationOUILabel specifies whether per-station byte and packet
+	// metrics are labeled with the manufacturer of each station, as
+	// determined by the OUI of its MAC address.  This increases the
+	// cardinality of those metrics.
+	StationOUILabel bool
+
+	// BetaFirmware matches device firmware versions which are considered
+	// to be from a beta release channel.  If nil, DefaultBetaFirmware is
+	// used.
+	BetaFirmware *regexp.Regexp
+
+	// ShardBySite specifies whether metrics for each site are collected
+	// concurrently, rather than one site after another.  This can reduce
+	// the time taken to scrape controllers with many sites.
+	ShardBySite bool
+
+	// Controller specifies a name for the UniFi Controller being scraped.
+	// If not empty, the total number of devices and stations for each site
+	// are additionally labeled with the controller name and the site's
+	// internal name, so that identically described sites on different
+	// controllers do not collide.
+	Controller string
+
+	// IdleThreshold specifies how long an associated station must go
+	// without traffic before it is counted as idle.  If zero,
+	// DefaultIdleThreshold is used.
+	IdleThreshold time.Duration
+
+	// SatisfactionWindow specifies the number of scrapes over which the
+	// moving average of device satisfaction is computed.  If zero,
+	// DefaultSatisfactionWindow is used.
+	SatisfactionWindow int
+
+	// MemoryTrendWindow specifies the number of scrapes over which the
+	// trend of device memory utilization is computed.  If zero,
+	// DefaultMemoryTrendWindow is used.
+	MemoryTrendWindow int
+
+	// WirelessDeviceTypes specifies the types of devices, such as "uap",
+	// for which wireless radio and traffic metrics are collected.  Devices
+	// which do not report a type are always included.  If nil,
+	// DefaultWirelessDeviceTypes is used.
+	WirelessDeviceTypes []string
+
+	// SiteConcurrency specifies the maximum number of sites for which each
+	// collector queries the UniFi Controller concurrently.  If zero,
+	// DefaultSiteConcurrency is used.
+	SiteConcurrency int
+
+	// CacheTTL specifies how long responses from the UniFi Controller, such
+	// as each site's devices, stations, and known clients, are reused.  If
+	// zero, responses are only shared by collectors within a single
+	// collection.
+	CacheTTL time.Duration
+
+	// ScrapeTimeout specifies how long the requests made by each collector
+	// during a single collection may take before they are canceled.  If
+	// zero, requests are only bounded by the UniFi client's HTTP timeout.
+	ScrapeTimeout time.Duration
+
+	// The following fields are set by New and shared by an Exporter and the
+	// collectors of all of its shards, so that state such as tracked series,
+	// suppressed errors, and cached responses is retained even when
+	// collectors are recreated.
+	logger     *errorLogger
+	counters   *counterTracker
+	uptimes    *counterTracker
+	channels   *changeTracker
+	deltas     *deltaTracker
+	states     *stateTracker
+	averages   *averageTracker
+	trends     *trendTracker
+	events     *eventTracker
+	endpoints  *endpointTracker
+	stations   *stationSet
+	cache      *responseCache
+	controller *controllerState
+}
+
+// Station identifiers which may be used as the label for per-station metrics.
+const (
+	StationLabelMAC      = "station_mac"
+	StationLabelHostname = "hostname"
+	StationLabelID       = "id"
+)
+
+// DefaultBetaFirmware is the regular expression used to classify device
+// firmware versions as beta when none is specified in a Config.
+var DefaultBetaFirmware = regexp.MustCompile(`(?i)(alpha|beta|rc)`)
+
+// betaFirmware returns the regular expression used to classify device
+// firmware versions as beta.
+func (c *Config) betaFirmware() *regexp.Regexp {
+	if c == nil || c.BetaFirmware == nil {
+		return DefaultBetaFirmware
+	}
+
+	return c.BetaFirmware
+}
+
+// ExperienceThresholds specifies the thresholds used to group wireless
+// stations into "poor", "fair", and "good" experience buckets.
+//
+// A station's satisfaction score is used if the UniFi Controller reports one;
+// otherwise its RSSI is used.  A station at or above a good threshold is
+// "good", at or above a fair threshold is "fair", and otherwise "poor".
+type ExperienceThresholds struct {
+	SatisfactionFair int
+	SatisfactionGood int
+
+	RSSIFair int
+	RSSIGood int
+}
+
+// DefaultExperienceThresholds are the ExperienceThresholds used when none
+// are specified in a Config.
+var DefaultExperienceThresholds = ExperienceThresholds{
+	SatisfactionFair: 50,
+	SatisfactionGood: 80,
+
+	RSSIFair: 15,
+	RSSIGood: 25,
+}
+
+// bucket returns the experience bucket for station s.
+func (t ExperienceThresholds) bucket(s *unifi.Station) string {
+	v, fair, good := s.RSSI, t.RSSIFair, t.RSSIGood
+	if s.Satisfaction >= 0 {
+		v, fair, good = s.Satisfaction, t.SatisfactionFair, t.SatisfactionGood
+	}
+
+	switch {
+	case v >= good:
+		return "good"
+	case v >= fair:
+		return "fair"
+	default:
+		return "poor"
+	}
+}
+
+// experienceThresholds returns the ExperienceThresholds specified by c, or
+// DefaultExperienceThresholds if none are specified.
+func (c *Config) experienceThresholds() ExperienceThresholds {
+	if c == nil || c.ExperienceThresholds == nil {
+		return DefaultExperienceThresholds
+	}
+
+	return *c.ExperienceThresholds
+}
+
+// validate checks c for invalid options.
+func (c *Config) validate() error {
+	if c.SatisfactionWindow < 0 {
+		return fmt.Errorf("invalid satisfaction window %d", c.SatisfactionWindow)
+	}
+	if c.MemoryTrendWindow < 0 {
+		return fmt.Errorf("invalid memory trend window %d", c.MemoryTrendWindow)
+	}
+	if c.SiteConcurrency < 0 {
+		return fmt.Errorf("invalid site concurrency %d", c.SiteConcurrency)
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("invalid cache TTL %v", c.CacheTTL)
+	}
+	if c.ScrapeTimeout < 0 {
+		return fmt.Errorf("invalid scrape timeout %v", c.ScrapeTimeout)
+	}
+	if c.ErrorLogInterval < 0 {
+		return fmt.Errorf("invalid error log interval %v", c.ErrorLogInterval)
+	}
+
+	switch c.StationLabel {
+	case "", StationLabelMAC, StationLabelHostname, StationLabelID:
+	default:
+		return fmt.Errorf("invalid station label %q", c.StationLabel)
+	}
+
+	return nil
+}
+
+// siteLabels returns the label names for aggregate per-site metrics, including
+// the controller and the site's internal name if controller is not empty.
+func siteLabels(controller string) []string {
+	if controller == "" {
+		return []string{"site"}
+	}
+
+	return []string{"controller", "site", "site_name"}
+}
+
+// siteLabelValues returns the label values for site s, in the order produced
+// by siteLabels.
+func siteLabelValues(controller string, s *unifi.Site) []string {
+	if controller == "" {
+		return []string{s.Description}
+	}
+
+	return []string{controller, s.Description, s.Name}
+}
+
+// DefaultIdleThreshold is the IdleThreshold used when none is specified in a
+// Config.
+const DefaultIdleThreshold = 5 * time.Minute
+
+// idleThreshold returns the IdleThreshold specified by c, or
+// DefaultIdleThreshold if none is specified.
+func (c *Config) idleThreshold() time.Duration {
+	if c == nil || c.IdleThreshold == 0 {
+		return DefaultIdleThreshold
+	}
+
+	return c.IdleThreshold
+}
+
+// DefaultSiteConcurrency is the SiteConcurrency used when none is specified
+// in a Config.
+const DefaultSiteConcurrency = 4
+
+// siteConcurrency returns the SiteConcurrency specified by c, or
+// DefaultSiteConcurrency if none is specified.
+func (c *Config) siteConcurrency() int {
+	if c == nil || c.SiteConcurrency <= 0 {
+		return DefaultSiteConcurrency
+	}
+
+	return c.SiteConcurrency
+}
+
+// DefaultSatisfactionWindow is the SatisfactionWindow used when none is
+// specified in a Config.
+const DefaultSatisfactionWindow = 5
+
+// averageTracker returns the averageTracker shared by collectors using c, or
+// a new averageTracker if c has none.
+func (c *Config) averageTracker() *averageTracker {
+	if c != nil && c.averages != nil {
+		return c.averages
+	}
+
+	window := DefaultSatisfactionWindow
+	if c != nil && c.SatisfactionWindow > 0 {
+		window = c.SatisfactionWindow
+	}
+
+	return newAverageTracker(window)
+}
+
+// DefaultMemoryTrendWindow is the MemoryTrendWindow used when none is
+// specified in a Config.
+const DefaultMemoryTrendWindow = 10
+
+// trendTracker returns the trendTracker shared by collectors using c, or a new
+// trendTracker if c has none.
+func (c *Config) trendTracker() *trendTracker {
+	if c != nil && c.trends != nil {
+		return c.trends
+	}
+
+	window := DefaultMemoryTrendWindow
+	if c != nil && c.MemoryTrendWindow > 0 {
+		window = c.MemoryTrendWindow
+	}
+
+	return newTrendTracker(window)
+}
+
+// eventTracker returns the eventTracker shared by collectors using c, or a new
+// eventTracker if c has none.
+func (c *Config) eventTracker() *eventTracker {
+	if c != nil && c.events != nil {
+		return c.events
+	}
+
+	return newEventTracker()
+}
+
+// DefaultWirelessDeviceTypes are the WirelessDeviceTypes used when none are
+// specified in a Config: access points, and gateways with built-in radios.
+var DefaultWirelessDeviceTypes = []string{"uap", "udm"}
+
+// wirelessDeviceTypes returns the set of device types for which wireless
+// metrics are collected.
+func (c *Config) wirelessDeviceTypes() map[string]bool {
+	types := DefaultWirelessDeviceTypes
+	if c != nil && c.WirelessDeviceTypes != nil {
+		types = c.WirelessDeviceTypes
+	}
+
+	m := make(map[string]bool, len(types))
+	for _, t := range types {
+		m[t] = true
+	}
+
+	return m
+}
+
+// endpointTracker returns the endpointTracker shared by collectors using c, or
+// nil if c has none.
+func (c *Config) endpointTracker() *endpointTracker {
+	if c == nil {
+		return nil
+	}
+
+	return c.endpoints
+}
+
+// stationSet returns the stationSet shared by collectors using c, or nil if c
+// has none.
+func (c *Config) stationSet() *stationSet {
+	if c == nil {
+		return nil
+	}
+
+	return c.stations
+}
+
+// controllerState returns the controllerState shared by collectors using c, or
+// nil if c has none.
+func (c *Config) controllerState() *controllerState {
+	if c == nil {
+		return nil
+	}
+
+	return c.controller
+}
+
+// responseCache returns c's responseCache, if one is configured.
+func (c *Config) responseCache() *responseCache {
+	if c == nil {
+		return nil
+	}
+
+	return c.cache
+}
+
+// orDefault returns c, or an empty Config if c is nil.
+func (c *Config) orDefault() *Config {
+	if c == nil {
+		return &Config{}
+	}
+
+	return c
+}
+
+// sweepTrackers evicts each series which was not seen since the previous
+// sweep from the trackers shared by collectors using c.
+func (c *Config) sweepTrackers() {
+	if c == nil {
+		return
+	}
+
+	var stores []*seriesStore
+	if c.counters != nil {
+		stores = append(stores, &c.counters.seriesStore)
+	}
+	if c.uptimes != nil {
+		stores = append(stores, &c.uptimes.seriesStore)
+	}
+	if c.channels != nil {
+		stores = append(stores, &c.channels.seriesStore)
+	}
+	if c.deltas != nil {
+		stores = append(stores, &c.deltas.seriesStore)
+	}
+	if c.states != nil {
+		stores = append(stores, &c.states.seriesStore)
+	}
+	if c.averages != nil {
+		stores = append(stores, &c.averages.seriesStore)
+	}
+	if c.trends != nil {
+		stores = append(stores, &c.trends.seriesStore)
+	}
+	if c.events != nil {
+		stores = append(stores, &c.events.seriesStore)
+	}
+
+	for _, s := range stores {
+		s.sweep()
+	}
+}
+
+// counterTracker returns the counterTracker shared by collectors using c, or
+// nil if c is not configured to expose monotonic counters.
+func (c *Config) counterTracker() *counterTracker {
+	if c == nil || !c.MonotonicCounters {
+		return nil
+	}
+	if c.counters == nil {
+		return newCounterTracker()
+	}
+
+	return c.counters
+}
+
+// stateTracker returns the stateTracker shared by collectors using c for
+// device states, or a new stateTracker if c has none.
+func (c *Config) stateTracker() *stateTracker {
+	if c == nil || c.states == nil {
+		return newStateTracker()
+	}
+
+	return c.states
+}
+
+// channelTracker returns the changeTracker shared by collectors using c for
+// radio channels, or a new changeTracker if c has none.
+func (c *Config) channelTracker() *changeTracker {
+	if c == nil || c.channels == nil {
+		return newChangeTracker()
+	}
+
+	return c.channels
+}
+
+// deltaTracker returns the deltaTracker shared by collectors using c for site
+// station counts, or a new deltaTracker if c has none.
+func (c *Config) deltaTracker() *deltaTracker {
+	if c == nil || c.deltas == nil {
+		return newDeltaTracker()
+	}
+
+	return c.deltas
+}
+
+// uptimeTracker returns the counterTracker shared by collectors using c for
+// device uptime, or nil if c is not configured to expose monotonic uptime.
+func (c *Config) uptimeTracker() *counterTracker {
+	if c == nil || !c.MonotonicUptime {
+		return nil
+	}
+	if c.uptimes == nil {
+		return newCounterTracker()
+	}
+
+	return c.uptimes
+}
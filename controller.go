@@ -0,0 +1,113 @@
+package unifiexporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/unifi"
+)
+
+// A controllerInfo is UniFi Controller-wide data which is retrieved once per
+// collection, rather than once for each site or shard.
+type controllerInfo struct {
+	// sites is the site list retrieved for this collection, keyed by site
+	// name, or nil if the site list could not be retrieved.
+	sites map[string]*unifi.Site
+
+	// sysInfo is nil if it could not be retrieved.
+	sysInfo *unifi.SysInfo
+}
+
+// now returns the current time according to the UniFi Controller, or the zero
+// time if it is not known.
+func (i *controllerInfo) now() time.Time {
+	if i.sysInfo == nil {
+		return time.Time{}
+	}
+
+	return i.sysInfo.Time
+}
+
+// site returns the site named by s from the site list retrieved for this
+// collection, or s itself if i is nil or s was not present in the list.
+func (i *controllerInfo) site(s *unifi.Site) *unifi.Site {
+	if i == nil {
+		return s
+	}
+
+	if fs, ok := i.sites[s.Name]; ok {
+		return fs
+	}
+
+	return s
+}
+
+// fetchControllerInfo retrieves a controllerInfo using c and ctx, retrieving
+// sysinfo through site.  Only a few metrics depend on sysinfo and the site
+// list, so failures to retrieve them are logged and the corresponding fields
+// are left nil.
+func fetchControllerInfo(ctx context.Context, c *unifi.Client, site string, cache *responseCache, endpoints *endpointTracker, logger *errorLogger) *controllerInfo {
+	info := &controllerInfo{}
+
+	// sysinfo is never cached, since it reports the UniFi Controller's
+	// current time
+	if site != "" {
+		si, err := c.SysInfoContext(ctx, site)
+		if err != nil {
+			logger.Printf("[ERROR] failed retrieving UniFi Controller sysinfo: %v", err)
+		} else {
+			info.sysInfo = si
+			endpoints.success(endpointSysInfo)
+		}
+	}
+
+	sites, err := cache.sites(ctx, c)
+	if err != nil {
+		logger.Printf("[ERROR] failed retrieving UniFi Controller sites: %v", err)
+		return info
+	}
+	endpoints.success(endpointSites)
+
+	info.sites = make(map[string]*unifi.Site, len(sites))
+	for _, s := range sites {
+		info.sites[s.Name] = s
+	}
+
+	return info
+}
+
+// A controllerState holds the controllerInfo retrieved by an Exporter for its
+// current collection, so that it can be shared by the collectors of each
+// shard.
+//
+// A nil *controllerState holds nothing.
+type controllerState struct {
+	mu   sync.Mutex
+	info *controllerInfo
+}
+
+// set stores info for the current collection.
+func (s *controllerState) set(info *controllerInfo) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.info = info
+}
+
+// get returns the controllerInfo for the current collection, or nil if none
+// has been stored.
+func (s *controllerState) get() *controllerInfo {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info
+}
@@ -0,0 +1,404 @@
+package unifiexporter
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A seriesStore holds the state of each series tracked by a tracker, keyed by
+// a series' descriptor and label values.
+//
+// Each series which is loaded is marked as seen, and sweep evicts every series
+// which was not seen since the previous sweep, so that state for devices and
+// stations which have gone away does not accumulate indefinitely.
+type seriesStore struct {
+	mu     sync.Mutex
+	series map[string]interface{}
+	seen   map[string]struct{}
+}
+
+// seriesKey returns the key for the series identified by desc and labels.
+func seriesKey(desc *prometheus.Desc, labels []string) string {
+	return desc.String() + "\xff" + strings.Join(labels, "\xff")
+}
+
+// load returns the state stored for key, and marks key as seen.
+//
+// load must be called with s's mutex locked.
+func (s *seriesStore) load(key string) (interface{}, bool) {
+	if s.seen == nil {
+		s.seen = make(map[string]struct{})
+	}
+	s.seen[key] = struct{}{}
+
+	v, ok := s.series[key]
+	return v, ok
+}
+
+// store stores v as the state for key.
+//
+// store must be called with s's mutex locked.
+func (s *seriesStore) store(key string, v interface{}) {
+	if s.series == nil {
+		s.series = make(map[string]interface{})
+	}
+
+	s.series[key] = v
+}
+
+// sweep evicts every series which has not been loaded since the previous
+// sweep.
+func (s *seriesStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.series {
+		if _, ok := s.seen[k]; !ok {
+			delete(s.series, k)
+		}
+	}
+
+	s.seen = make(map[string]struct{})
+}
+
+// A counterTracker tracks the last value observed for each counter series,
+// so that a counter which is reset by a UniFi device or the UniFi Controller
+// can be exposed as a monotonically increasing value.
+//
+// A nil *counterTracker returns all values unmodified.
+type counterTracker struct {
+	seriesStore
+}
+
+// A counterState is the state of a single counter series.
+type counterState struct {
+	last   float64
+	offset float64
+}
+
+// newCounterTracker creates an empty counterTracker.
+func newCounterTracker() *counterTracker {
+	return &counterTracker{}
+}
+
+// value returns the monotonic value for the series identified by desc and
+// labels, given its current raw value v.  When v is less than the previous
+// raw value, the counter is assumed to have been reset, and the previous
+// value is carried forward so that the exposed value never decreases.
+func (t *counterTracker) value(desc *prometheus.Desc, v float64, labels ...string) float64 {
+	if t == nil {
+		return v
+	}
+
+	key := seriesKey(desc, labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sv, ok := t.load(key)
+	if !ok {
+		t.store(key, &counterState{last: v})
+		return v
+	}
+
+	s := sv.(*counterState)
+	if v < s.last {
+		s.offset += s.last
+	}
+	s.last = v
+
+	return s.offset + v
+}
+
+// A changeTracker counts the number of times the value of each series changes
+// between collections.
+//
+// A nil *changeTracker reports no changes.
+type changeTracker struct {
+	seriesStore
+}
+
+// A changeState is the state of a single tracked series.
+type changeState struct {
+	last    float64
+	changes int
+}
+
+// newChangeTracker creates an empty changeTracker.
+func newChangeTracker() *changeTracker {
+	return &changeTracker{}
+}
+
+// changes records v as the current value for the series identified by desc
+// and labels, and returns the number of times its value has changed.
+func (t *changeTracker) changes(desc *prometheus.Desc, v float64, labels ...string) int {
+	if t == nil {
+		return 0
+	}
+
+	key := seriesKey(desc, labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sv, ok := t.load(key)
+	if !ok {
+		t.store(key, &changeState{last: v})
+		return 0
+	}
+
+	s := sv.(*changeState)
+	if v != s.last {
+		s.changes++
+	}
+	s.last = v
+
+	return s.changes
+}
+
+// A deltaTracker computes the change in the value of each series since the
+// previous collection.
+//
+// A nil *deltaTracker reports no change.
+type deltaTracker struct {
+	seriesStore
+}
+
+// newDeltaTracker creates an empty deltaTracker.
+func newDeltaTracker() *deltaTracker {
+	return &deltaTracker{}
+}
+
+// delta records v as the current value for the series identified by desc and
+// labels, and returns the difference between v and its previous value.
+func (t *deltaTracker) delta(desc *prometheus.Desc, v float64, labels ...string) float64 {
+	if t == nil {
+		return 0
+	}
+
+	key := seriesKey(desc, labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.load(key)
+	t.store(key, v)
+	if !ok {
+		return 0
+	}
+
+	return v - last.(float64)
+}
+
+// An averageTracker computes a moving average over the most recent values of
+// each series.
+//
+// A nil *averageTracker returns all values unmodified.
+type averageTracker struct {
+	seriesStore
+	window int
+}
+
+// newAverageTracker creates an empty averageTracker which averages over the
+// most recent window values of each series.
+func newAverageTracker(window int) *averageTracker {
+	return &averageTracker{
+		window: window,
+	}
+}
+
+// average records v as the current value for the series identified by desc
+// and labels, and returns the average of its most recent values.
+func (t *averageTracker) average(desc *prometheus.Desc, v float64, labels ...string) float64 {
+	if t == nil {
+		return v
+	}
+
+	key := seriesKey(desc, labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, _ := t.load(key)
+	vs, _ := last.([]float64)
+	vs = append(vs, v)
+	if len(vs) > t.window {
+		vs = vs[len(vs)-t.window:]
+	}
+	t.store(key, vs)
+
+	var sum float64
+	for _, v := range vs {
+		sum += v
+	}
+
+	return sum / float64(len(vs))
+}
+
+// A trendTracker computes the least-squares slope of the most recent values of
+// each series, in units per recorded value.
+//
+// A nil *trendTracker reports no trend for all series.
+type trendTracker struct {
+	seriesStore
+	window int
+}
+
+// newTrendTracker creates an empty trendTracker which computes the slope of the
+// most recent window values of each series.
+func newTrendTracker(window int) *trendTracker {
+	return &trendTracker{
+		window: window,
+	}
+}
+
+// slope records v as the current value for the series identified by desc and
+// labels, and returns the slope of its most recent values.  Fewer than two
+// values have a slope of zero.
+func (t *trendTracker) slope(desc *prometheus.Desc, v float64, labels ...string) float64 {
+	if t == nil {
+		return 0
+	}
+
+	key := seriesKey(desc, labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, _ := t.load(key)
+	vs, _ := last.([]float64)
+	vs = append(vs, v)
+	if len(vs) > t.window {
+		vs = vs[len(vs)-t.window:]
+	}
+	t.store(key, vs)
+
+	if len(vs) < 2 {
+		return 0
+	}
+
+	// Values are evenly spaced, so each value's index is its x coordinate
+	var sx, sy, sxx, sxy float64
+	for i, y := range vs {
+		x := float64(i)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+
+	n := float64(len(vs))
+	return (n*sxy - sx*sy) / (n*sxx - sx*sx)
+}
+
+// A stateTracker tracks how long each series has remained in its current
+// state.
+//
+// A nil *stateTracker reports that every state was just entered.
+type stateTracker struct {
+	seriesStore
+}
+
+// A stateEntry is the state of a single tracked series.
+type stateEntry struct {
+	state string
+	since time.Time
+}
+
+// newStateTracker creates an empty stateTracker.
+func newStateTracker() *stateTracker {
+	return &stateTracker{}
+}
+
+// duration records state as the current state at time now for the series
+// identified by desc and labels, and returns how long the series has been in
+// that state.
+func (t *stateTracker) duration(desc *prometheus.Desc, state string, now time.Time, labels ...string) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	key := seriesKey(desc, labels)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sv, ok := t.load(key)
+	if s, _ := sv.(*stateEntry); ok && s.state == state {
+		return now.Sub(s.since)
+	}
+
+	t.store(key, &stateEntry{
+		state: state,
+		since: now,
+	})
+	return 0
+}
+
+// An eventTracker counts the events of each kind recorded by the UniFi
+// Controller for each series, so that the recent events which it reports can
+// be exposed as monotonically increasing counters.
+//
+// A nil *eventTracker counts only the events reported in each collection.
+type eventTracker struct {
+	seriesStore
+}
+
+// An eventState is the state of a single tracked series.
+type eventState struct {
+	newest time.Time
+	totals map[string]float64
+}
+
+// newEventTracker creates an empty eventTracker.
+func newEventTracker() *eventTracker {
+	return &eventTracker{}
+}
+
+// count counts events by the kind returned by kind for the series identified
+// by desc and labels, and returns the total number of events of each kind
+// counted so far.  Events for which kind returns the empty string are
+// ignored, and events which are not newer than the newest event previously
+// counted are assumed to have been counted already.
+func (t *eventTracker) count(desc *prometheus.Desc, events []*unifi.Event, kind func(e *unifi.Event) string, labels ...string) map[string]float64 {
+	s := &eventState{totals: make(map[string]float64)}
+
+	if t != nil {
+		key := seriesKey(desc, labels)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if sv, ok := t.load(key); ok {
+			s = sv.(*eventState)
+		} else {
+			t.store(key, s)
+		}
+	}
+
+	newest := s.newest
+	for _, e := range events {
+		if !e.DateTime.After(s.newest) {
+			continue
+		}
+		if e.DateTime.After(newest) {
+			newest = e.DateTime
+		}
+
+		if k := kind(e); k != "" {
+			s.totals[k]++
+		}
+	}
+	s.newest = newest
+
+	totals := make(map[string]float64, len(s.totals))
+	for k, v := range s.totals {
+		totals[k] = v
+	}
+
+	return totals
+}
@@ -0,0 +1,330 @@
+package unifiexporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCounterTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo_total", "foo", []string{"site"}, nil)
+
+	var tests = []struct {
+		desc string
+		ct   *counterTracker
+		in   []float64
+		out  []float64
+	}{
+		{
+			desc: "nil tracker",
+			in:   []float64{10, 5, 20},
+			out:  []float64{10, 5, 20},
+		},
+		{
+			desc: "increment",
+			ct:   newCounterTracker(),
+			in:   []float64{10, 15, 15, 30},
+			out:  []float64{10, 15, 15, 30},
+		},
+		{
+			desc: "reset",
+			ct:   newCounterTracker(),
+			in:   []float64{10, 15, 5, 8, 2},
+			out:  []float64{10, 15, 20, 23, 25},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j := range tt.in {
+			if want, got := tt.out[j], tt.ct.value(desc, tt.in[j], "Default"); want != got {
+				t.Fatalf("[%02d] unexpected value:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+
+	// Series with different labels must be tracked independently.
+	ct := newCounterTracker()
+	ct.value(desc, 100, "Default")
+	if want, got := 10.0, ct.value(desc, 10, "Other"); want != got {
+		t.Fatalf("unexpected value for independent series:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestChangeTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo", "foo", []string{"site"}, nil)
+
+	var tests = []struct {
+		desc string
+		ct   *changeTracker
+		in   []float64
+		out  []int
+	}{
+		{
+			desc: "nil tracker",
+			in:   []float64{1, 6, 11},
+			out:  []int{0, 0, 0},
+		},
+		{
+			desc: "no changes",
+			ct:   newChangeTracker(),
+			in:   []float64{1, 1, 1},
+			out:  []int{0, 0, 0},
+		},
+		{
+			desc: "changes",
+			ct:   newChangeTracker(),
+			in:   []float64{1, 6, 6, 11, 1},
+			out:  []int{0, 1, 1, 2, 3},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j := range tt.in {
+			if want, got := tt.out[j], tt.ct.changes(desc, tt.in[j], "Default"); want != got {
+				t.Fatalf("[%02d] unexpected changes:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+}
+
+func TestDeltaTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo", "foo", []string{"site"}, nil)
+
+	var tests = []struct {
+		desc string
+		dt   *deltaTracker
+		in   []float64
+		out  []float64
+	}{
+		{
+			desc: "nil tracker",
+			in:   []float64{1, 6, 11},
+			out:  []float64{0, 0, 0},
+		},
+		{
+			desc: "no changes",
+			dt:   newDeltaTracker(),
+			in:   []float64{1, 1, 1},
+			out:  []float64{0, 0, 0},
+		},
+		{
+			desc: "changes",
+			dt:   newDeltaTracker(),
+			in:   []float64{10, 12, 12, 2, 5},
+			out:  []float64{0, 2, 0, -10, 3},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j := range tt.in {
+			if want, got := tt.out[j], tt.dt.delta(desc, tt.in[j], "Default"); want != got {
+				t.Fatalf("[%02d] unexpected delta:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+}
+
+func TestAverageTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo", "foo", []string{"site"}, nil)
+
+	var tests = []struct {
+		desc string
+		at   *averageTracker
+		in   []float64
+		out  []float64
+	}{
+		{
+			desc: "nil tracker",
+			in:   []float64{1, 0.5, 0},
+			out:  []float64{1, 0.5, 0},
+		},
+		{
+			desc: "window not full",
+			at:   newAverageTracker(4),
+			in:   []float64{1, 0.5, 0},
+			out:  []float64{1, 0.75, 0.5},
+		},
+		{
+			desc: "window full",
+			at:   newAverageTracker(2),
+			in:   []float64{1, 0.5, 0, 0},
+			out:  []float64{1, 0.75, 0.25, 0},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j := range tt.in {
+			if want, got := tt.out[j], tt.at.average(desc, tt.in[j], "Default"); want != got {
+				t.Fatalf("[%02d] unexpected value:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+}
+
+func TestTrendTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo", "foo", []string{"site"}, nil)
+
+	var tests = []struct {
+		desc string
+		tt   *trendTracker
+		in   []float64
+		out  []float64
+	}{
+		{
+			desc: "nil tracker",
+			in:   []float64{0, 0.5, 1},
+			out:  []float64{0, 0, 0},
+		},
+		{
+			desc: "rising values",
+			tt:   newTrendTracker(4),
+			in:   []float64{0, 0.5, 1},
+			out:  []float64{0, 0.5, 0.5},
+		},
+		{
+			desc: "window full",
+			tt:   newTrendTracker(2),
+			in:   []float64{0, 0.5, 0.5, 0},
+			out:  []float64{0, 0.5, 0, -0.5},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j := range tt.in {
+			if want, got := tt.out[j], tt.tt.slope(desc, tt.in[j], "Default"); want != got {
+				t.Fatalf("[%02d] unexpected value:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+}
+
+func TestStateTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo", "foo", []string{"site"}, nil)
+
+	type step struct {
+		state string
+		now   int64
+	}
+
+	var tests = []struct {
+		desc string
+		st   *stateTracker
+		in   []step
+		out  []time.Duration
+	}{
+		{
+			desc: "nil tracker",
+			in:   []step{{"a", 0}, {"a", 10}},
+			out:  []time.Duration{0, 0},
+		},
+		{
+			desc: "state persists",
+			st:   newStateTracker(),
+			in:   []step{{"a", 0}, {"a", 10}, {"a", 30}},
+			out:  []time.Duration{0, 10 * time.Second, 30 * time.Second},
+		},
+		{
+			desc: "state changes",
+			st:   newStateTracker(),
+			in:   []step{{"a", 0}, {"a", 10}, {"b", 20}, {"b", 25}, {"a", 30}},
+			out:  []time.Duration{0, 10 * time.Second, 0, 5 * time.Second, 0},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j, s := range tt.in {
+			if want, got := tt.out[j], tt.st.duration(desc, s.state, time.Unix(s.now, 0), "Default"); want != got {
+				t.Fatalf("[%02d] unexpected duration:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+}
+
+func TestEventTracker(t *testing.T) {
+	desc := prometheus.NewDesc("foo_total", "foo", []string{"site", "kind"}, nil)
+
+	kind := func(e *unifi.Event) string {
+		return e.Key
+	}
+
+	events := func(ts ...int64) []*unifi.Event {
+		var es []*unifi.Event
+		for _, t := range ts {
+			es = append(es, &unifi.Event{
+				Key:      "a",
+				DateTime: time.Unix(t, 0),
+			})
+		}
+
+		return es
+	}
+
+	var tests = []struct {
+		desc string
+		et   *eventTracker
+		in   [][]*unifi.Event
+		out  []float64
+	}{
+		{
+			desc: "nil tracker",
+			in:   [][]*unifi.Event{events(1, 2), events(1, 2, 3)},
+			out:  []float64{2, 3},
+		},
+		{
+			desc: "new events",
+			et:   newEventTracker(),
+			in:   [][]*unifi.Event{events(1, 2), events(1, 2, 3), events(3), events()},
+			out:  []float64{2, 3, 3, 3},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		for j := range tt.in {
+			if want, got := tt.out[j], tt.et.count(desc, tt.in[j], kind, "Default")["a"]; want != got {
+				t.Fatalf("[%02d] unexpected count:\n- want: %v\n-  got: %v", j, want, got)
+			}
+		}
+	}
+}
+
+func TestSeriesStoreSweep(t *testing.T) {
+	desc := prometheus.NewDesc("foo_total", "foo", []string{"site"}, nil)
+
+	ct := newCounterTracker()
+	ct.value(desc, 100, "Default")
+	ct.value(desc, 100, "Other")
+	ct.sweep()
+
+	// Only the first series is seen before the next sweep, so the second
+	// is evicted
+	ct.value(desc, 10, "Default")
+	ct.sweep()
+
+	if want, got := 110.0, ct.value(desc, 10, "Default"); want != got {
+		t.Fatalf("unexpected value for retained series:\n- want: %v\n-  got: %v", want, got)
+	}
+	if want, got := 10.0, ct.value(desc, 10, "Other"); want != got {
+		t.Fatalf("unexpected value for evicted series:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// Trackers which are not configured are skipped
+	var cfg *Config
+	cfg.sweepTrackers()
+	(&Config{}).sweepTrackers()
+}
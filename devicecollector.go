@@ -1,7 +1,9 @@
 package unifiexporter
 
 import (
-	"log"
+	"context"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/mdlayher/unifi"
@@ -14,8 +16,25 @@ type DeviceCollector struct {
 	Devices          *prometheus.Desc
 	AdoptedDevices   *prometheus.Desc
 	UnadoptedDevices *prometheus.Desc
+	PendingProvision *prometheus.Desc
 
-	UptimeSecondsTotal *prometheus.Desc
+	DeviceCountMismatch *prometheus.Desc
+	DevicesByChannel    *prometheus.Desc
+	ChannelsInUse       *prometheus.Desc
+	APsWithNoClients    *prometheus.Desc
+
+	UptimeSecondsTotal   *prometheus.Desc
+	SecondsSinceLastSeen *prometheus.Desc
+	LastSeenTimestamp    *prometheus.Desc
+	StateDurationSeconds *prometheus.Desc
+	State                *prometheus.Desc
+
+	SatisfactionRatio    *prometheus.Desc
+	SatisfactionRatioAvg *prometheus.Desc
+	MemoryTrendRatio     *prometheus.Desc
+
+	IPInfo *prometheus.Desc
+	Info   *prometheus.Desc
 
 	WirelessReceivedBytesTotal    *prometheus.Desc
 	WirelessTransmittedBytesTotal *prometheus.Desc
@@ -24,26 +43,85 @@ type DeviceCollector struct {
 	WirelessTransmittedPacketsTotal *prometheus.Desc
 	WirelessTransmittedDroppedTotal *prometheus.Desc
 
+	WirelessReceivedBytesUserTotal     *prometheus.Desc
+	WirelessTransmittedBytesUserTotal  *prometheus.Desc
+	WirelessReceivedBytesGuestTotal    *prometheus.Desc
+	WirelessTransmittedBytesGuestTotal *prometheus.Desc
+
+	WirelessReceivedPacketsUserTotal     *prometheus.Desc
+	WirelessTransmittedPacketsUserTotal  *prometheus.Desc
+	WirelessReceivedPacketsGuestTotal    *prometheus.Desc
+	WirelessTransmittedPacketsGuestTotal *prometheus.Desc
+
 	WiredReceivedBytesTotal    *prometheus.Desc
 	WiredTransmittedBytesTotal *prometheus.Desc
 
+	TotalBytes *prometheus.Desc
+
 	WiredReceivedPacketsTotal    *prometheus.Desc
 	WiredTransmittedPacketsTotal *prometheus.Desc
+	WiredErrorRatio              *prometheus.Desc
+
+	WiredReceivedErrorsTotal    *prometheus.Desc
+	WiredTransmittedErrorsTotal *prometheus.Desc
 
 	Stations      *prometheus.Desc
 	UserStations  *prometheus.Desc
 	GuestStations *prometheus.Desc
+	MaxStations   *prometheus.Desc
+
+	RadioMaxTXPowerDBM *prometheus.Desc
+	RadioMinTXPowerDBM *prometheus.Desc
+
+	RadioAntennaGainDBI *prometheus.Desc
+	RadioBuiltInAntenna *prometheus.Desc
+
+	BandImbalanceRatio *prometheus.Desc
+
+	RadioBeaconsTotal        *prometheus.Desc
+	RadioProbeResponsesTotal *prometheus.Desc
+	RadioChannelChangesTotal *prometheus.Desc
+
+	BroadcastSSIDs *prometheus.Desc
+
+	PortVLAN          *prometheus.Desc
+	PortRxErrorsTotal *prometheus.Desc
+	PortTxErrorsTotal *prometheus.Desc
+	PortDroppedTotal  *prometheus.Desc
+
+	MeshUplinkRSSIDBM *prometheus.Desc
+
+	CountryInfo *prometheus.Desc
+
+	LEDEnabled *prometheus.Desc
 
 	c     *unifi.Client
 	sites []*unifi.Site
+
+	timestamps  bool
+	counters    *counterTracker
+	uptimes     *counterTracker
+	channels    *changeTracker
+	states      *stateTracker
+	averages    *averageTracker
+	trends      *trendTracker
+	beta        *regexp.Regexp
+	controller  string
+	wireless    map[string]bool
+	concurrency int
+	endpoints   *endpointTracker
+	cache       *responseCache
+	info        *controllerState
+	timeout     time.Duration
+	logger      *errorLogger
 }
 
 // Verify that the Exporter implements the collector interface.
 var _ collector = &DeviceCollector{}
 
 // NewDeviceCollector creates a new DeviceCollector which collects metrics for
-// a specified site.
-func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *DeviceCollector {
 	const (
 		subsystem = "devices"
 	)
@@ -51,7 +129,10 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 	var (
 		labelsSiteOnly       = []string{"site"}
 		labelsDevice         = []string{"site", "id", "mac", "name"}
+		labelsDeviceIP       = []string{"site", "id", "mac", "name", "ip"}
+		labelsDeviceInfo     = []string{"site", "id", "mac", "name", "model", "version", "serial", "hardware_revision", "anon_id"}
 		labelsDeviceStations = []string{"site", "id", "mac", "name", "interface", "radio"}
+		labelsPort           = []string{"site", "device_mac", "port_idx"}
 	)
 
 	return &DeviceCollector{
@@ -59,7 +140,7 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			// Subsystem is used as name so we get "unifi_devices"
 			prometheus.BuildFQName(namespace, "", subsystem),
 			"Total number of devices",
-			labelsSiteOnly,
+			siteLabels(cfg.orDefault().Controller),
 			nil,
 		),
 
@@ -77,6 +158,41 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			nil,
 		),
 
+		PendingProvision: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "pending_provision"),
+			"Number of devices which are still being provisioned with configuration changes",
+			labelsSiteOnly,
+			nil,
+		),
+
+		DeviceCountMismatch: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "device_count_mismatch"),
+			"Difference between the number of devices the controller reports managing for a site and the number of adopted devices",
+			labelsSiteOnly,
+			nil,
+		),
+
+		DevicesByChannel: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "devices_by_channel"),
+			"Number of devices running firmware from each release channel",
+			[]string{"site", "channel"},
+			nil,
+		),
+
+		ChannelsInUse: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "channels_in_use"),
+			"Number of distinct wireless channels in use by radios in each band",
+			[]string{"site", "band"},
+			nil,
+		),
+
+		APsWithNoClients: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "aps_with_no_clients"),
+			"Number of access points with no stations connected to any radio",
+			labelsSiteOnly,
+			nil,
+		),
+
 		UptimeSecondsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "uptime_seconds_total"),
 			"Device uptime in seconds",
@@ -84,6 +200,69 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			nil,
 		),
 
+		SatisfactionRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "satisfaction_ratio"),
+			"Device satisfaction score reported by the controller, as a ratio from 0 to 1",
+			labelsDevice,
+			nil,
+		),
+
+		SatisfactionRatioAvg: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "satisfaction_ratio_avg"),
+			"Moving average of device satisfaction over the most recent scrapes, as a ratio from 0 to 1",
+			labelsDevice,
+			nil,
+		),
+
+		MemoryTrendRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_trend_ratio"),
+			"Change in device memory utilization per scrape over the most recent scrapes, as a ratio of total memory",
+			labelsDevice,
+			nil,
+		),
+
+		SecondsSinceLastSeen: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "seconds_since_last_seen"),
+			"Number of seconds since the UniFi Controller last heard from devices, according to the controller's clock",
+			labelsDevice,
+			nil,
+		),
+
+		LastSeenTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "last_seen_timestamp_seconds"),
+			"UNIX timestamp of the last time the UniFi Controller heard from devices",
+			labelsDevice,
+			nil,
+		),
+
+		StateDurationSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "state_duration_seconds"),
+			"Number of seconds devices have remained in their current state other than connected, according to the controller's clock",
+			[]string{"site", "device_mac", "state"},
+			nil,
+		),
+
+		State: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "state"),
+			"State code reported for devices by the UniFi Controller, such as 0 (disconnected) or 1 (connected)",
+			labelsDevice,
+			nil,
+		),
+
+		IPInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "ip_info"),
+			"IP addresses assigned to devices, including each WAN address on gateways",
+			labelsDeviceIP,
+			nil,
+		),
+
+		Info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "info"),
+			"Model, firmware version, and serial number of devices",
+			labelsDeviceInfo,
+			nil,
+		),
+
 		WirelessReceivedBytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "wireless_received_bytes_total"),
 			"Number of bytes received wirelessly by devices",
@@ -119,6 +298,62 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			nil,
 		),
 
+		WirelessReceivedBytesUserTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_received_bytes_user_total"),
+			"Number of bytes received wirelessly by devices on user networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessTransmittedBytesUserTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_transmitted_bytes_user_total"),
+			"Number of bytes transmitted wirelessly by devices on user networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessReceivedBytesGuestTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_received_bytes_guest_total"),
+			"Number of bytes received wirelessly by devices on guest networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessTransmittedBytesGuestTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_transmitted_bytes_guest_total"),
+			"Number of bytes transmitted wirelessly by devices on guest networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessReceivedPacketsUserTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_received_packets_user_total"),
+			"Number of packets received wirelessly by devices on user networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessTransmittedPacketsUserTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_transmitted_packets_user_total"),
+			"Number of packets transmitted wirelessly by devices on user networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessReceivedPacketsGuestTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_received_packets_guest_total"),
+			"Number of packets received wirelessly by devices on guest networks",
+			labelsDevice,
+			nil,
+		),
+
+		WirelessTransmittedPacketsGuestTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wireless_transmitted_packets_guest_total"),
+			"Number of packets transmitted wirelessly by devices on guest networks",
+			labelsDevice,
+			nil,
+		),
+
 		WiredReceivedBytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "wired_received_bytes_total"),
 			"Number of bytes received using wired interface by devices",
@@ -133,6 +368,13 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			nil,
 		),
 
+		TotalBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "total_bytes"),
+			"Number of bytes transferred by devices over all interfaces",
+			labelsDevice,
+			nil,
+		),
+
 		WiredReceivedPacketsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "wired_received_packets_total"),
 			"Number of packets received using wired interface by devices",
@@ -147,6 +389,27 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			nil,
 		),
 
+		WiredErrorRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wired_error_ratio"),
+			"Ratio of receive and transmit errors to packets using wired interface by devices",
+			labelsDevice,
+			nil,
+		),
+
+		WiredReceivedErrorsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wired_received_errors_total"),
+			"Number of receive errors using wired interface by devices",
+			labelsDevice,
+			nil,
+		),
+
+		WiredTransmittedErrorsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "wired_transmitted_errors_total"),
+			"Number of transmit errors using wired interface by devices",
+			labelsDevice,
+			nil,
+		),
+
 		Stations: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "stations"),
 			"Total number of stations (clients) connected to devices",
@@ -168,36 +431,237 @@ func NewDeviceCollector(c *unifi.Client, sites []*unifi.Site) *DeviceCollector {
 			nil,
 		),
 
+		MaxStations: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_max_stations"),
+			"Maximum number of stations (clients) configured for device radios",
+			labelsDeviceStations,
+			nil,
+		),
+
+		RadioMaxTXPowerDBM: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_max_tx_power_dbm"),
+			"Maximum transmit power supported by device radios in dBm",
+			labelsDeviceStations,
+			nil,
+		),
+
+		RadioMinTXPowerDBM: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_min_tx_power_dbm"),
+			"Minimum transmit power supported by device radios in dBm",
+			labelsDeviceStations,
+			nil,
+		),
+
+		RadioAntennaGainDBI: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_antenna_gain_dbi"),
+			"Gain of the built-in antenna of device radios in dBi",
+			labelsDeviceStations,
+			nil,
+		),
+
+		RadioBuiltInAntenna: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_builtin_antenna"),
+			"Whether device radios use their built-in antenna (1) or not (0)",
+			labelsDeviceStations,
+			nil,
+		),
+
+		RadioChannelChangesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_channel_changes_total"),
+			"Number of times the channel of a radio has changed while observed by the exporter",
+			labelsDeviceStations,
+			nil,
+		),
+
+		BandImbalanceRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "band_imbalance_ratio"),
+			"Ratio of stations connected to a device's busiest radio to all stations connected to the device",
+			labelsDevice,
+			nil,
+		),
+
+		RadioBeaconsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_beacons_total"),
+			"Number of beacon frames transmitted by a radio",
+			labelsDeviceStations,
+			nil,
+		),
+
+		RadioProbeResponsesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "radio_probe_responses_total"),
+			"Number of probe response frames transmitted by a radio",
+			labelsDeviceStations,
+			nil,
+		),
+
+		BroadcastSSIDs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "broadcast_ssids"),
+			"Number of SSIDs broadcast by devices, counting each radio separately",
+			labelsDevice,
+			nil,
+		),
+
+		PortVLAN: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "device", "port_vlan"),
+			"Native VLAN ID assigned to switch ports",
+			labelsPort,
+			nil,
+		),
+
+		PortRxErrorsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "device", "port_rx_errors_total"),
+			"Number of receive errors on switch ports",
+			labelsPort,
+			nil,
+		),
+
+		PortTxErrorsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "device", "port_tx_errors_total"),
+			"Number of transmit errors on switch ports",
+			labelsPort,
+			nil,
+		),
+
+		PortDroppedTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "device", "port_dropped_total"),
+			"Number of packets dropped on receive or transmit by switch ports",
+			labelsPort,
+			nil,
+		),
+
+		MeshUplinkRSSIDBM: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "mesh_uplink_rssi_dbm"),
+			"Signal strength of the wireless uplink from mesh APs to their parent AP",
+			[]string{"site", "device_mac", "uplink_mac"},
+			nil,
+		),
+
+		CountryInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "country_info"),
+			"Regulatory domain configured on devices, as an ISO 3166-1 numeric country code",
+			[]string{"site", "device_mac", "country"},
+			nil,
+		),
+
+		LEDEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "led_enabled"),
+			"Whether the LEDs of devices are enabled, according to their LED override mode",
+			[]string{"site", "device_mac"},
+			nil,
+		),
+
 		c:     c,
 		sites: sites,
+
+		timestamps:  cfg.orDefault().Timestamps,
+		counters:    cfg.counterTracker(),
+		uptimes:     cfg.uptimeTracker(),
+		channels:    cfg.channelTracker(),
+		states:      cfg.stateTracker(),
+		averages:    cfg.averageTracker(),
+		trends:      cfg.trendTracker(),
+		beta:        cfg.betaFirmware(),
+		controller:  cfg.orDefault().Controller,
+		wireless:    cfg.wirelessDeviceTypes(),
+		concurrency: cfg.siteConcurrency(),
+		endpoints:   cfg.endpointTracker(),
+		cache:       cfg.responseCache(),
+		info:        cfg.controllerState(),
+		timeout:     cfg.orDefault().ScrapeTimeout,
+		logger:      cfg.orDefault().logger,
 	}
 }
 
 // collect begins a metrics collection task for all metrics related to UniFi
 // devices.
 func (c *DeviceCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
-	for _, s := range c.sites {
-		devices, err := c.c.Devices(s.Name)
-		if err != nil {
-			return c.Devices, err
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	// An Exporter retrieves controller-wide data once for all of its
+	// collectors, so a DeviceCollector only does so when used on its own
+	info := c.info.get()
+	if info == nil {
+		var site string
+		if len(c.sites) > 0 {
+			site = c.sites[0].Name
 		}
 
-		ch <- prometheus.MustNewConstMetric(
-			c.Devices,
-			prometheus.GaugeValue,
-			float64(len(devices)),
-			s.Description,
-		)
+		info = fetchControllerInfo(ctx, c.c, site, c.cache, c.endpoints, c.logger)
+	}
 
-		c.collectDeviceAdoptions(ch, s.Description, devices)
-		c.collectDeviceUptime(ch, s.Description, devices)
-		c.collectDeviceBytes(ch, s.Description, devices)
-		c.collectDeviceStations(ch, s.Description, devices)
+	return forEachSite(c.sites, c.concurrency, func(s *unifi.Site) (*prometheus.Desc, error) {
+		return c.collectSite(ctx, ch, s, info)
+	})
+}
+
+// collectSite collects metrics for the UniFi devices in site s, using ctx for
+// requests and info for controller-wide data.
+func (c *DeviceCollector) collectSite(ctx context.Context, ch chan<- prometheus.Metric, s *unifi.Site, info *controllerInfo) (*prometheus.Desc, error) {
+	devices, err := c.cache.devices(ctx, c.c, s.Name)
+	if err != nil {
+		return c.Devices, &siteError{site: s, err: err}
 	}
+	c.endpoints.success(endpointDevices)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.Devices,
+		prometheus.GaugeValue,
+		float64(len(devices)),
+		siteLabelValues(c.controller, s)...,
+	)
+
+	// Wireless metrics are only collected for wireless device types
+	wireless := c.wirelessDevices(devices)
+
+	c.collectDeviceAdoptions(ch, s.Description, devices)
+	c.collectDevicePendingProvision(ch, s.Description, devices)
+	if fs, ok := info.sites[s.Name]; ok {
+		c.collectDeviceCountMismatch(ch, s.Description, fs.NumAPs, devices)
+	}
+	c.collectDeviceChannels(ch, s.Description, devices)
+	c.collectRadioChannelsInUse(ch, s.Description, wireless)
+	c.collectDeviceUptime(ch, s.Description, devices)
+	c.collectDeviceLastSeen(ch, s.Description, info.now(), devices)
+	c.collectDeviceLastSeenTimestamps(ch, s.Description, devices)
+	c.collectDeviceStateDurations(ch, s.Description, info.now(), devices)
+	c.collectDeviceStates(ch, s.Description, devices)
+	c.collectDeviceSatisfaction(ch, s.Description, devices)
+	c.collectDeviceMemoryTrend(ch, s.Description, devices)
+	c.collectDeviceIPs(ch, s.Description, devices)
+	c.collectDeviceInfo(ch, s.Description, devices)
+	c.collectDeviceBytes(ch, s.Description, devices)
+	c.collectDeviceStations(ch, s.Description, wireless)
+	c.collectAPsWithNoClients(ch, s.Description, wireless)
+	c.collectDeviceSSIDs(ch, s.Description, wireless)
+	c.collectDeviceBandImbalance(ch, s.Description, wireless)
+	c.collectDevicePorts(ch, s.Description, devices)
+	c.collectDeviceMeshUplinks(ch, s.Description, devices)
+	c.collectDeviceCountries(ch, s.Description, devices)
+	c.collectDeviceLEDs(ch, s.Description, devices)
 
 	return nil, nil
 }
 
+// isWireless reports whether wireless metrics are collected for device d.
+// Devices which do not report a type are assumed to be wireless.
+func (c *DeviceCollector) isWireless(d *unifi.Device) bool {
+	return d.Type == "" || c.wireless[d.Type]
+}
+
+// wirelessDevices returns the devices for which wireless metrics are
+// collected.
+func (c *DeviceCollector) wirelessDevices(devices []*unifi.Device) []*unifi.Device {
+	wireless := make([]*unifi.Device, 0, len(devices))
+	for _, d := range devices {
+		if c.isWireless(d) {
+			wireless = append(wireless, d)
+		}
+	}
+
+	return wireless
+}
+
 // collectDeviceAdoptions collects counts for number of adopted and unadopted
 // UniFi devices.
 func (c *DeviceCollector) collectDeviceAdoptions(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
@@ -226,102 +690,445 @@ func (c *DeviceCollector) collectDeviceAdoptions(ch chan<- prometheus.Metric, si
 	)
 }
 
+// collectDevicePendingProvision collects the number of UniFi devices which
+// have not yet applied their configuration changes.
+func (c *DeviceCollector) collectDevicePendingProvision(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	var pending int
+	for _, d := range devices {
+		if deviceState(d) == "provisioning" {
+			pending++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.PendingProvision,
+		prometheus.GaugeValue,
+		float64(pending),
+		siteLabel,
+	)
+}
+
+// collectDeviceCountMismatch collects the difference between numAPs, the
+// number of devices a site reports, and the number of adopted UniFi devices.
+func (c *DeviceCollector) collectDeviceCountMismatch(ch chan<- prometheus.Metric, siteLabel string, numAPs int, devices []*unifi.Device) {
+	var adopted int
+	for _, d := range devices {
+		if d.Adopted {
+			adopted++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.DeviceCountMismatch,
+		prometheus.GaugeValue,
+		float64(numAPs-adopted),
+		siteLabel,
+	)
+}
+
+// collectDeviceChannels collects the number of devices running firmware from
+// the stable and beta release channels.
+func (c *DeviceCollector) collectDeviceChannels(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	var stable, beta int
+	for _, d := range devices {
+		if c.beta.MatchString(d.Version) {
+			beta++
+		} else {
+			stable++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.DevicesByChannel,
+		prometheus.GaugeValue,
+		float64(stable),
+		siteLabel,
+		"stable",
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.DevicesByChannel,
+		prometheus.GaugeValue,
+		float64(beta),
+		siteLabel,
+		"beta",
+	)
+}
+
+// collectRadioChannelsInUse collects the number of distinct wireless channels
+// in use by the radios of UniFi devices in each band.
+func (c *DeviceCollector) collectRadioChannelsInUse(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	bands := make(map[string]map[int]struct{})
+	for _, d := range devices {
+		for _, r := range d.Radios {
+			// Radios which do not report a channel are not counted
+			if r.Channel == 0 {
+				continue
+			}
+
+			if _, ok := bands[r.Radio]; !ok {
+				bands[r.Radio] = make(map[int]struct{})
+			}
+			bands[r.Radio][r.Channel] = struct{}{}
+		}
+	}
+
+	for b, chans := range bands {
+		ch <- prometheus.MustNewConstMetric(
+			c.ChannelsInUse,
+			prometheus.GaugeValue,
+			float64(len(chans)),
+			siteLabel,
+			b,
+		)
+	}
+}
+
 // collectDeviceUptime collects device uptime for UniFi devices.
 func (c *DeviceCollector) collectDeviceUptime(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
 	for _, d := range devices {
 		labels := []string{
 			siteLabel,
 			d.ID,
-			d.NICs[0].MAC.String(),
+			deviceMAC(d),
 			d.Name,
 		}
 
+		// Uptime is accumulated across reboots if c is configured to
+		// expose monotonic uptime
 		ch <- prometheus.MustNewConstMetric(
 			c.UptimeSecondsTotal,
 			prometheus.CounterValue,
-			float64(d.Uptime/time.Second),
+			c.uptimes.value(c.UptimeSecondsTotal, float64(d.Uptime/time.Second), labels...),
 			labels...,
 		)
 	}
 }
 
-// collectDeviceBytes collects receive and transmit byte counts for UniFi devices.
-func (c *DeviceCollector) collectDeviceBytes(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+// collectDeviceSatisfaction collects the satisfaction score of UniFi devices
+// which report one, and its moving average across collections.
+func (c *DeviceCollector) collectDeviceSatisfaction(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
 	for _, d := range devices {
+		if d.Satisfaction < 0 {
+			continue
+		}
+
 		labels := []string{
 			siteLabel,
 			d.ID,
-			d.NICs[0].MAC.String(),
+			deviceMAC(d),
 			d.Name,
 		}
 
+		v := float64(d.Satisfaction) / 100
+
 		ch <- prometheus.MustNewConstMetric(
-			c.WirelessReceivedBytesTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.All.ReceiveBytes),
+			c.SatisfactionRatio,
+			prometheus.GaugeValue,
+			v,
 			labels...,
 		)
+
 		ch <- prometheus.MustNewConstMetric(
-			c.WirelessTransmittedBytesTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.All.TransmitBytes),
+			c.SatisfactionRatioAvg,
+			prometheus.GaugeValue,
+			c.averages.average(c.SatisfactionRatioAvg, v, labels...),
 			labels...,
 		)
+	}
+}
+
+// collectDeviceMemoryTrend collects the trend of memory utilization of UniFi
+// devices which report their memory usage.
+func (c *DeviceCollector) collectDeviceMemoryTrend(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		if d.MemoryTotal <= 0 {
+			continue
+		}
+
+		labels := []string{
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
+		}
+
+		v := float64(d.MemoryUsed) / float64(d.MemoryTotal)
 
 		ch <- prometheus.MustNewConstMetric(
-			c.WirelessReceivedPacketsTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.All.ReceivePackets),
+			c.MemoryTrendRatio,
+			prometheus.GaugeValue,
+			c.trends.slope(c.MemoryTrendRatio, v, labels...),
 			labels...,
 		)
+	}
+}
+
+// collectDeviceLastSeen collects the number of seconds since the UniFi
+// Controller last heard from UniFi devices, using now as the controller's
+// current time.
+func (c *DeviceCollector) collectDeviceLastSeen(ch chan<- prometheus.Metric, siteLabel string, now time.Time, devices []*unifi.Device) {
+	// No time reported by the controller
+	if now.IsZero() {
+		return
+	}
+
+	for _, d := range devices {
+		// Device has never been seen
+		if d.LastSeen.Unix() <= 0 {
+			continue
+		}
+
+		// The Date header only has second precision, so a device seen during
+		// the current second may appear to be seen in the future
+		since := now.Sub(d.LastSeen)
+		if since < 0 {
+			since = 0
+		}
+
 		ch <- prometheus.MustNewConstMetric(
-			c.WirelessTransmittedPacketsTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.All.TransmitPackets),
-			labels...,
+			c.SecondsSinceLastSeen,
+			prometheus.GaugeValue,
+			since.Seconds(),
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
 		)
+	}
+}
+
+// collectDeviceLastSeenTimestamps collects the time the UniFi Controller last
+// heard from UniFi devices.
+func (c *DeviceCollector) collectDeviceLastSeenTimestamps(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		// Device has never been seen
+		if d.LastSeen.Unix() <= 0 {
+			continue
+		}
+
 		ch <- prometheus.MustNewConstMetric(
-			c.WirelessTransmittedDroppedTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.All.TransmitDropped),
-			labels...,
+			c.LastSeenTimestamp,
+			prometheus.GaugeValue,
+			float64(d.LastSeen.Unix()),
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
 		)
+	}
+}
+
+// deviceStates maps device states reported by the UniFi Controller to names.
+var deviceStates = map[int]string{
+	0:  "disconnected",
+	1:  "connected",
+	2:  "pending",
+	4:  "upgrading",
+	5:  "provisioning",
+	6:  "heartbeat_missed",
+	7:  "adopting",
+	9:  "adoption_failed",
+	10: "isolated",
+}
+
+// deviceState returns the name of the state of UniFi device d.  Unknown states
+// are named by their numeric value.
+func deviceState(d *unifi.Device) string {
+	if s, ok := deviceStates[d.State]; ok {
+		return s
+	}
+
+	return strconv.Itoa(d.State)
+}
 
+// collectDeviceStates collects the state code of UniFi devices.
+func (c *DeviceCollector) collectDeviceStates(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
 		ch <- prometheus.MustNewConstMetric(
-			c.WiredReceivedBytesTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.Uplink.ReceiveBytes),
-			labels...,
+			c.State,
+			prometheus.GaugeValue,
+			float64(d.State),
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
 		)
+	}
+}
+
+// collectDeviceStateDurations collects the time UniFi devices have remained
+// in their current state, for devices which are not connected.
+func (c *DeviceCollector) collectDeviceStateDurations(ch chan<- prometheus.Metric, siteLabel string, now time.Time, devices []*unifi.Device) {
+	// No time reported by the controller
+	if now.IsZero() {
+		return
+	}
+
+	for _, d := range devices {
+		mac := deviceMAC(d)
+
+		// Track connected devices too, so the duration restarts once a
+		// device leaves the connected state
+		state := deviceState(d)
+		since := c.states.duration(c.StateDurationSeconds, state, now, siteLabel, mac)
+		if state == "connected" {
+			continue
+		}
+
 		ch <- prometheus.MustNewConstMetric(
-			c.WiredTransmittedBytesTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.Uplink.TransmitBytes),
-			labels...,
+			c.StateDurationSeconds,
+			prometheus.GaugeValue,
+			since.Seconds(),
+			siteLabel,
+			mac,
+			state,
 		)
+	}
+}
+
+// collectDeviceIPs collects IP address information for UniFi devices.
+func (c *DeviceCollector) collectDeviceIPs(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		for _, ip := range d.IPs {
+			ch <- prometheus.MustNewConstMetric(
+				c.IPInfo,
+				prometheus.GaugeValue,
+				1,
+				siteLabel,
+				d.ID,
+				deviceMAC(d),
+				d.Name,
+				ip.String(),
+			)
+		}
+	}
+}
 
+// collectDeviceInfo collects model, firmware version, and serial number
+// information for UniFi devices.
+func (c *DeviceCollector) collectDeviceInfo(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
 		ch <- prometheus.MustNewConstMetric(
-			c.WiredReceivedPacketsTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.Uplink.ReceivePackets),
-			labels...,
+			c.Info,
+			prometheus.GaugeValue,
+			1,
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
+			d.Model,
+			d.Version,
+			d.Serial,
+			hardwareRevision(d),
+			d.AnonID,
 		)
+	}
+}
+
+// hardwareRevision returns the hardware revision label value for device d, or
+// empty if d does not report a hardware revision.
+func hardwareRevision(d *unifi.Device) string {
+	if d.BoardRevision == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(d.BoardRevision)
+}
+
+// deviceMAC returns the MAC address of the first network interface of d, or
+// its top-level MAC address if it reports no network interfaces.  An empty
+// string is returned if d reports neither.
+func deviceMAC(d *unifi.Device) string {
+	if len(d.NICs) > 0 {
+		return d.NICs[0].MAC.String()
+	}
+
+	return d.MAC.String()
+}
+
+// collectDeviceBytes collects receive and transmit byte counts for UniFi devices.
+func (c *DeviceCollector) collectDeviceBytes(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		labels := []string{
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
+		}
+
+		if c.isWireless(d) {
+			ch <- c.lastSeen(c.counter(c.WirelessReceivedBytesTotal, float64(d.Stats.All.ReceiveBytes), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedBytesTotal, float64(d.Stats.All.TransmitBytes), labels...), d)
+
+			ch <- c.lastSeen(c.counter(c.WirelessReceivedPacketsTotal, float64(d.Stats.All.ReceivePackets), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedPacketsTotal, float64(d.Stats.All.TransmitPackets), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedDroppedTotal, float64(d.Stats.All.TransmitDropped), labels...), d)
+
+			ch <- c.lastSeen(c.counter(c.WirelessReceivedBytesUserTotal, float64(d.Stats.User.ReceiveBytes), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedBytesUserTotal, float64(d.Stats.User.TransmitBytes), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessReceivedBytesGuestTotal, float64(d.Stats.Guest.ReceiveBytes), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedBytesGuestTotal, float64(d.Stats.Guest.TransmitBytes), labels...), d)
+
+			ch <- c.lastSeen(c.counter(c.WirelessReceivedPacketsUserTotal, float64(d.Stats.User.ReceivePackets), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedPacketsUserTotal, float64(d.Stats.User.TransmitPackets), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessReceivedPacketsGuestTotal, float64(d.Stats.Guest.ReceivePackets), labels...), d)
+			ch <- c.lastSeen(c.counter(c.WirelessTransmittedPacketsGuestTotal, float64(d.Stats.Guest.TransmitPackets), labels...), d)
+		}
+
+		ch <- c.lastSeen(c.counter(c.WiredReceivedBytesTotal, float64(d.Stats.Uplink.ReceiveBytes), labels...), d)
+		ch <- c.lastSeen(c.counter(c.WiredTransmittedBytesTotal, float64(d.Stats.Uplink.TransmitBytes), labels...), d)
+
+		ch <- c.lastSeen(c.counter(c.TotalBytes, d.Stats.TotalBytes, labels...), d)
+
+		ch <- c.lastSeen(c.counter(c.WiredReceivedPacketsTotal, float64(d.Stats.Uplink.ReceivePackets), labels...), d)
+		ch <- c.lastSeen(c.counter(c.WiredTransmittedPacketsTotal, float64(d.Stats.Uplink.TransmitPackets), labels...), d)
+
+		ch <- c.lastSeen(c.counter(c.WiredReceivedErrorsTotal, float64(d.Stats.Uplink.ReceiveErrors), labels...), d)
+		ch <- c.lastSeen(c.counter(c.WiredTransmittedErrorsTotal, float64(d.Stats.Uplink.TransmitErrors), labels...), d)
+
+		// Avoid dividing by zero for devices with no wired traffic
+		packets := d.Stats.Uplink.ReceivePackets + d.Stats.Uplink.TransmitPackets
+		if packets == 0 {
+			continue
+		}
+
 		ch <- prometheus.MustNewConstMetric(
-			c.WiredTransmittedPacketsTotal,
-			prometheus.CounterValue,
-			float64(d.Stats.Uplink.TransmitPackets),
+			c.WiredErrorRatio,
+			prometheus.GaugeValue,
+			(d.Stats.Uplink.ReceiveErrors+d.Stats.Uplink.TransmitErrors)/packets,
 			labels...,
 		)
 	}
 }
 
+// lastSeen applies the time the UniFi Controller last saw d to m, if c is
+// configured to expose timestamps.
+func (c *DeviceCollector) lastSeen(m prometheus.Metric, d *unifi.Device) prometheus.Metric {
+	if !c.timestamps {
+		return m
+	}
+
+	return withTimestamp(m, d.LastSeen)
+}
+
+// counter creates a counter metric for desc with value v, which is adjusted
+// for counter resets if c is configured to expose monotonic counters.
+func (c *DeviceCollector) counter(desc *prometheus.Desc, v float64, labels ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(
+		desc,
+		prometheus.CounterValue,
+		c.counters.value(desc, v, labels...),
+		labels...,
+	)
+}
+
 // collectDeviceStations collects station counts for UniFi devices.
 func (c *DeviceCollector) collectDeviceStations(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
 	for _, d := range devices {
 		labels := []string{
 			siteLabel,
 			d.ID,
-			d.NICs[0].MAC.String(),
+			deviceMAC(d),
 			d.Name,
 		}
 
@@ -351,7 +1158,253 @@ func (c *DeviceCollector) collectDeviceStations(ch chan<- prometheus.Metric, sit
 				float64(r.Stats.NumberGuestStations),
 				llabels...,
 			)
+
+			// Only radios with a configured limit report a maximum
+			if r.MaxStations != 0 {
+				ch <- prometheus.MustNewConstMetric(
+					c.MaxStations,
+					prometheus.GaugeValue,
+					float64(r.MaxStations),
+					llabels...,
+				)
+			}
+
+			var builtIn float64
+			if r.BuiltInAntenna {
+				builtIn = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				c.RadioAntennaGainDBI,
+				prometheus.GaugeValue,
+				float64(r.BuiltInAntennaGain),
+				llabels...,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				c.RadioBuiltInAntenna,
+				prometheus.GaugeValue,
+				builtIn,
+				llabels...,
+			)
+
+			// Transmit power range is only reported by some radios
+			if r.MaxTXPower != 0 {
+				ch <- prometheus.MustNewConstMetric(
+					c.RadioMaxTXPowerDBM,
+					prometheus.GaugeValue,
+					float64(r.MaxTXPower),
+					llabels...,
+				)
+				ch <- prometheus.MustNewConstMetric(
+					c.RadioMinTXPowerDBM,
+					prometheus.GaugeValue,
+					float64(r.MinTXPower),
+					llabels...,
+				)
+			}
+
+			// Channel changes are only tracked for radios which report
+			// their channel
+			if r.Channel != 0 {
+				ch <- prometheus.MustNewConstMetric(
+					c.RadioChannelChangesTotal,
+					prometheus.CounterValue,
+					float64(c.channels.changes(c.RadioChannelChangesTotal, float64(r.Channel), llabels...)),
+					llabels...,
+				)
+			}
+
+			// Management frame counts are not reported by all devices
+			if r.Frames == nil {
+				continue
+			}
+
+			ch <- c.counter(c.RadioBeaconsTotal, float64(r.Frames.Beacons), llabels...)
+			ch <- c.counter(c.RadioProbeResponsesTotal, float64(r.Frames.ProbeResponses), llabels...)
+		}
+	}
+}
+
+// deviceTypeAP is the type reported by UniFi access points.
+const deviceTypeAP = "uap"
+
+// collectAPsWithNoClients collects the number of UniFi access points which
+// have no stations connected to any of their radios.  Only devices which
+// report themselves as access points and report radios are counted.
+func (c *DeviceCollector) collectAPsWithNoClients(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	var n int
+	for _, d := range devices {
+		if d.Type != deviceTypeAP || len(d.Radios) == 0 {
+			continue
+		}
+
+		var stations int
+		for _, r := range d.Radios {
+			if r.Stats == nil {
+				continue
+			}
+
+			stations += r.Stats.NumberStations
 		}
+
+		if stations == 0 {
+			n++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.APsWithNoClients,
+		prometheus.GaugeValue,
+		float64(n),
+		siteLabel,
+	)
+}
+
+// collectDeviceBandImbalance collects the ratio of stations connected to the
+// busiest radio of UniFi devices to all stations connected to the device.
+func (c *DeviceCollector) collectDeviceBandImbalance(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		var max, total int
+		for _, r := range d.Radios {
+			if r.Stats == nil {
+				continue
+			}
+
+			n := r.Stats.NumberStations
+			total += n
+			if n > max {
+				max = n
+			}
+		}
+
+		// Avoid dividing by zero for devices with no stations
+		if total == 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.BandImbalanceRatio,
+			prometheus.GaugeValue,
+			float64(max)/float64(total),
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
+		)
+	}
+}
+
+// collectDeviceSSIDs collects the number of SSIDs broadcast by UniFi devices.
+func (c *DeviceCollector) collectDeviceSSIDs(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		// Devices without radios do not broadcast any SSIDs
+		if len(d.Radios) == 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.BroadcastSSIDs,
+			prometheus.GaugeValue,
+			float64(len(d.VAPs)),
+			siteLabel,
+			d.ID,
+			deviceMAC(d),
+			d.Name,
+		)
+	}
+}
+
+// collectDevicePorts collects error and drop counters, and the native VLAN
+// assigned, for each port of UniFi switches.
+func (c *DeviceCollector) collectDevicePorts(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		for _, p := range d.Ports {
+			labels := []string{
+				siteLabel,
+				deviceMAC(d),
+				strconv.Itoa(p.Index),
+			}
+
+			ch <- c.counter(c.PortRxErrorsTotal, p.ReceiveErrors, labels...)
+			ch <- c.counter(c.PortTxErrorsTotal, p.TransmitErrors, labels...)
+			ch <- c.counter(c.PortDroppedTotal, p.ReceiveDropped+p.TransmitDropped, labels...)
+
+			// Ports without a native VLAN are not reported
+			if p.VLAN == 0 {
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				c.PortVLAN,
+				prometheus.GaugeValue,
+				float64(p.VLAN),
+				labels...,
+			)
+		}
+	}
+}
+
+// collectDeviceMeshUplinks collects the signal strength of the wireless uplink
+// of UniFi mesh APs.
+func (c *DeviceCollector) collectDeviceMeshUplinks(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		if d.Uplink == nil || d.Uplink.Type != unifi.UplinkWireless {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.MeshUplinkRSSIDBM,
+			prometheus.GaugeValue,
+			float64(d.Uplink.Signal),
+			siteLabel,
+			deviceMAC(d),
+			d.Uplink.MAC.String(),
+		)
+	}
+}
+
+// collectDeviceCountries collects the regulatory domain configured on UniFi
+// devices.
+func (c *DeviceCollector) collectDeviceCountries(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		// Devices which do not report a country are not reported
+		if d.CountryCode == 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.CountryInfo,
+			prometheus.GaugeValue,
+			1,
+			siteLabel,
+			deviceMAC(d),
+			strconv.Itoa(d.CountryCode),
+		)
+	}
+}
+
+// collectDeviceLEDs collects whether the LEDs of UniFi devices are enabled.
+func (c *DeviceCollector) collectDeviceLEDs(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device) {
+	for _, d := range devices {
+		// Devices which do not report an LED override are not reported
+		if d.LEDOverride == "" {
+			continue
+		}
+
+		// Only an explicit "off" override disables the LEDs; "default"
+		// follows the site setting, which enables them
+		var enabled float64
+		if d.LEDOverride != "off" {
+			enabled = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.LEDEnabled,
+			prometheus.GaugeValue,
+			enabled,
+			siteLabel,
+			deviceMAC(d),
+		)
 	}
 }
 
@@ -362,8 +1415,25 @@ func (c *DeviceCollector) Describe(ch chan<- *prometheus.Desc) {
 		c.Devices,
 		c.AdoptedDevices,
 		c.UnadoptedDevices,
+		c.PendingProvision,
+
+		c.DeviceCountMismatch,
+		c.DevicesByChannel,
+		c.ChannelsInUse,
+		c.APsWithNoClients,
 
 		c.UptimeSecondsTotal,
+		c.SecondsSinceLastSeen,
+		c.LastSeenTimestamp,
+		c.StateDurationSeconds,
+		c.State,
+
+		c.SatisfactionRatio,
+		c.SatisfactionRatioAvg,
+		c.MemoryTrendRatio,
+
+		c.IPInfo,
+		c.Info,
 
 		c.WirelessReceivedBytesTotal,
 		c.WirelessTransmittedBytesTotal,
@@ -372,15 +1442,57 @@ func (c *DeviceCollector) Describe(ch chan<- *prometheus.Desc) {
 		c.WirelessTransmittedPacketsTotal,
 		c.WirelessTransmittedDroppedTotal,
 
+		c.WirelessReceivedBytesUserTotal,
+		c.WirelessTransmittedBytesUserTotal,
+		c.WirelessReceivedBytesGuestTotal,
+		c.WirelessTransmittedBytesGuestTotal,
+
+		c.WirelessReceivedPacketsUserTotal,
+		c.WirelessTransmittedPacketsUserTotal,
+		c.WirelessReceivedPacketsGuestTotal,
+		c.WirelessTransmittedPacketsGuestTotal,
+
 		c.WiredReceivedBytesTotal,
 		c.WiredTransmittedBytesTotal,
 
+		c.TotalBytes,
+
 		c.WiredReceivedPacketsTotal,
 		c.WiredTransmittedPacketsTotal,
+		c.WiredErrorRatio,
+
+		c.WiredReceivedErrorsTotal,
+		c.WiredTransmittedErrorsTotal,
 
 		c.Stations,
 		c.UserStations,
 		c.GuestStations,
+		c.MaxStations,
+
+		c.RadioMaxTXPowerDBM,
+		c.RadioMinTXPowerDBM,
+
+		c.RadioAntennaGainDBI,
+		c.RadioBuiltInAntenna,
+
+		c.BandImbalanceRatio,
+
+		c.RadioBeaconsTotal,
+		c.RadioProbeResponsesTotal,
+		c.RadioChannelChangesTotal,
+
+		c.BroadcastSSIDs,
+
+		c.PortVLAN,
+		c.PortRxErrorsTotal,
+		c.PortTxErrorsTotal,
+		c.PortDroppedTotal,
+
+		c.MeshUplinkRSSIDBM,
+
+		c.CountryInfo,
+
+		c.LEDEnabled,
 	}
 
 	for _, d := range ds {
@@ -400,7 +1512,7 @@ func (c *DeviceCollector) Collect(ch chan<- prometheus.Metric) {
 func (c *DeviceCollector) CollectError(ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
 		ch <- prometheus.NewInvalidMetric(desc, err)
-		log.Printf("[ERROR] failed collecting device metric %v: %v", desc, err)
+		c.logger.Printf("[ERROR] failed collecting device metric %v: %v", desc, err)
 		return err
 	}
 
@@ -1,9 +1,14 @@
 package unifiexporter
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mdlayher/unifi"
 )
@@ -12,6 +17,7 @@ func TestDeviceCollector(t *testing.T) {
 	var tests = []struct {
 		desc    string
 		input   string
+		cfg     *Config
 		sites   []*unifi.Site
 		matches []*regexp.Regexp
 	}{
@@ -24,6 +30,7 @@ func TestDeviceCollector(t *testing.T) {
 			"_id": "abc",
 			"adopted": true,
 			"inform_ip": "192.168.1.1",
+			"ip": "192.168.1.20",
 			"name": "ABC",
 			"ethernet_table": [{
 				"mac": "de:ad:be:ef:de:ad"
@@ -75,6 +82,8 @@ func TestDeviceCollector(t *testing.T) {
 
 				regexp.MustCompile(`unifi_devices_uptime_seconds_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 10`),
 
+				regexp.MustCompile(`unifi_devices_ip_info{id="abc",ip="192.168.1.20",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 1`),
+
 				regexp.MustCompile(`unifi_devices_wireless_received_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 80`),
 				regexp.MustCompile(`unifi_devices_wireless_transmitted_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 20`),
 
@@ -94,6 +103,8 @@ func TestDeviceCollector(t *testing.T) {
 				regexp.MustCompile(`unifi_devices_stations_user{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 4`),
 				regexp.MustCompile(`unifi_devices_stations_guest{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 1`),
 				regexp.MustCompile(`unifi_devices_stations_guest{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 2`),
+
+				regexp.MustCompile(`unifi_devices_band_imbalance_ratio{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 0.6666666666666666`),
 			},
 			sites: []*unifi.Site{{
 				Name:        "default",
@@ -369,30 +380,1899 @@ func TestDeviceCollector(t *testing.T) {
 				},
 			},
 		},
-	}
-
-	for i, tt := range tests {
-		t.Logf("[%02d] test %q", i, tt.desc)
-
-		out := testDeviceCollector(t, []byte(tt.input), tt.sites)
-
-		for j, m := range tt.matches {
-			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
-
-			if !m.Match(out) {
-				t.Fatal("\toutput failed to match regex.")
-			}
+		{
+			desc: "one device with wired errors, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {
+				"rx_bytes": 2000,
+				"tx_bytes": 1000,
+				"rx_packets": 60,
+				"tx_packets": 40,
+				"rx_errors": 3,
+				"tx_errors": 2
+			},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_wired_received_packets_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 60`),
+				regexp.MustCompile(`unifi_devices_wired_transmitted_packets_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 40`),
+				regexp.MustCompile(`unifi_devices_wired_error_ratio{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 0.05`),
+				regexp.MustCompile(`unifi_devices_wired_received_errors_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 3`),
+				regexp.MustCompile(`unifi_devices_wired_transmitted_errors_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 2`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "devices with no ethernet table entries, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"mac": "de:ad:be:ef:de:ad",
+			"name": "ABC",
+			"ethernet_table": [],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"num_sta": 3
+			}],
+			"radio_table": [{
+				"name": "wifi0",
+				"radio": "ng"
+			}],
+			"stat": {
+				"rx_bytes": 80
+			},
+			"uptime": 10
+		},
+		{
+			"_id": "def",
+			"adopted": true,
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"stat": {},
+			"uptime": 20
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_uptime_seconds_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 10`),
+				regexp.MustCompile(`unifi_devices_uptime_seconds_total{id="def",mac="",name="DEF",site="Default"} 20`),
+				regexp.MustCompile(`unifi_devices_wireless_received_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 80`),
+				regexp.MustCompile(`unifi_devices_stations{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 3`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with total bytes, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {
+				"bytes": 100
+			},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_total_bytes{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 100`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one dual WAN gateway, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "gw",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"ip": "203.0.113.10",
+			"name": "Gateway",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"wan1": {
+				"ip": "203.0.113.10"
+			},
+			"wan2": {
+				"ip": "198.51.100.20"
+			},
+			"stat": {},
+			"uplink": {},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_ip_info{id="gw",ip="203.0.113.10",mac="de:ad:be:ef:de:ad",name="Gateway",site="Default"} 1`),
+				regexp.MustCompile(`unifi_devices_ip_info{id="gw",ip="198.51.100.20",mac="de:ad:be:ef:de:ad",name="Gateway",site="Default"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with timestamps, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"last_seen": 1500000000,
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {
+				"rx_bytes": 80
+			},
+			"uplink": {
+				"rx_bytes": 20
+			},
+			"uptime": 10
+		}
+	]
+}
+`),
+			cfg: &Config{
+				Timestamps: true,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_uptime_seconds_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 10\n`),
+				regexp.MustCompile(`unifi_devices_wireless_received_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 80 1500000000000`),
+				regexp.MustCompile(`unifi_devices_wired_received_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 20 1500000000000`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with radio client limit, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"num_sta": 3
+			}, {
+				"name": "wifi1",
+				"num_sta": 6
+			}],
+			"radio_table": [
+				{
+					"name": "wifi0",
+					"radio": "ng"
+				},
+				{
+					"name": "wifi1",
+					"radio": "na",
+					"max_sta": 50
+				}
+			],
+			"stat": {},
+			"uplink": {},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_stations{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 6`),
+				regexp.MustCompile(`unifi_devices_radio_max_stations{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 50`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with radio transmit power ranges, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0"
+			}, {
+				"name": "wifi1"
+			}],
+			"radio_table": [
+				{
+					"name": "wifi0",
+					"radio": "ng",
+					"max_txpower": 23,
+					"min_txpower": 6
+				},
+				{
+					"name": "wifi1",
+					"radio": "na",
+					"max_txpower": 22,
+					"min_txpower": 6
+				}
+			],
+			"stat": {},
+			"uplink": {},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_radio_max_tx_power_dbm{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 23`),
+				regexp.MustCompile(`unifi_devices_radio_min_tx_power_dbm{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 6`),
+				regexp.MustCompile(`unifi_devices_radio_max_tx_power_dbm{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 22`),
+				regexp.MustCompile(`unifi_devices_radio_min_tx_power_dbm{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 6`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with built-in and external antennas, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0"
+			}, {
+				"name": "wifi1"
+			}],
+			"radio_table": [
+				{
+					"name": "wifi0",
+					"radio": "ng",
+					"builtin_antenna": true,
+					"builtin_ant_gain": 3
+				},
+				{
+					"name": "wifi1",
+					"radio": "na",
+					"builtin_antenna": false,
+					"builtin_ant_gain": 0
+				}
+			],
+			"stat": {},
+			"uplink": {},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_radio_antenna_gain_dbi{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 3`),
+				regexp.MustCompile(`unifi_devices_radio_builtin_antenna{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 1`),
+				regexp.MustCompile(`unifi_devices_radio_antenna_gain_dbi{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 0`),
+				regexp.MustCompile(`unifi_devices_radio_builtin_antenna{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device broadcasting SSIDs, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0"
+			}, {
+				"name": "wifi1"
+			}],
+			"radio_table": [
+				{
+					"name": "wifi0",
+					"radio": "ng"
+				},
+				{
+					"name": "wifi1",
+					"radio": "na"
+				}
+			],
+			"vap_table": [
+				{
+					"bssid": "de:ad:be:ef:de:a0",
+					"essid": "Home",
+					"radio": "ng",
+					"radio_name": "wifi0"
+				},
+				{
+					"bssid": "de:ad:be:ef:de:a1",
+					"essid": "Home",
+					"radio": "na",
+					"radio_name": "wifi1"
+				},
+				{
+					"bssid": "de:ad:be:ef:de:a2",
+					"essid": "Guest",
+					"radio": "na",
+					"radio_name": "wifi1"
+				}
+			],
+			"stat": {},
+			"uplink": {},
+			"uptime": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_broadcast_ssids{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 3`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device reporting management frames, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+					"name": "wifi0",
+					"tx_beacon": 1000,
+					"tx_probe_resp": 250
+				}, {
+					"name": "wifi1"
+			}],
+			"radio_table": [
+				{
+					"name": "wifi0",
+					"radio": "ng"
+				},
+				{
+					"name": "wifi1",
+					"radio": "na"
+				}
+			],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_radio_beacons_total{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 1000`),
+				regexp.MustCompile(`unifi_devices_radio_probe_responses_total{id="abc",interface="wifi0",mac="de:ad:be:ef:de:ad",name="ABC",radio="2.4GHz",site="Default"} 250`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "devices on mixed firmware channels, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"version": "3.7.58.6385",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"version": "3.8.1-beta.7012",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "ghi",
+			"inform_ip": "192.168.1.3",
+			"name": "GHI",
+			"version": "3.8.0.6900-rc2",
+			"ethernet_table": [{
+				"mac": "a0:a0:a0:a0:a0:a0"
+			}],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_devices_by_channel{channel="stable",site="Default"} 1`),
+				regexp.MustCompile(`unifi_site_devices_by_channel{channel="beta",site="Default"} 2`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "devices on mixed firmware channels with custom regex, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"version": "3.7.58.6385",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"version": "3.8.1-beta.7012",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "ghi",
+			"inform_ip": "192.168.1.3",
+			"name": "GHI",
+			"version": "3.8.0.6900-rc2",
+			"ethernet_table": [{
+				"mac": "a0:a0:a0:a0:a0:a0"
+			}],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`),
+			cfg: &Config{
+				BetaFirmware: regexp.MustCompile(`-beta`),
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_devices_by_channel{channel="stable",site="Default"} 2`),
+				regexp.MustCompile(`unifi_site_devices_by_channel{channel="beta",site="Default"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one switch with VLAN assignments, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Switch",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"port_table": [
+				{
+					"port_idx": 1,
+					"name": "Port 1",
+					"up": true,
+					"vlan": 10
+				},
+				{
+					"port_idx": 2,
+					"name": "Port 2",
+					"up": true,
+					"vlan": 20
+				},
+				{
+					"port_idx": 3,
+					"name": "Port 3"
+				}
+			],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_device_port_vlan{device_mac="de:ad:be:ef:de:ad",port_idx="1",site="Default"} 10`),
+				regexp.MustCompile(`unifi_device_port_vlan{device_mac="de:ad:be:ef:de:ad",port_idx="2",site="Default"} 20`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "three APs sharing channels, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "AP1",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [
+				{"name": "wifi0", "channel": 1},
+				{"name": "wifi1", "channel": 36}
+			],
+			"radio_table": [
+				{"name": "wifi0", "radio": "ng"},
+				{"name": "wifi1", "radio": "na"}
+			],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "AP2",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"radio_table_stats": [
+				{"name": "wifi0", "channel": 6},
+				{"name": "wifi1", "channel": 149}
+			],
+			"radio_table": [
+				{"name": "wifi0", "radio": "ng"},
+				{"name": "wifi1", "radio": "na"}
+			],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "ghi",
+			"inform_ip": "192.168.1.3",
+			"name": "AP3",
+			"ethernet_table": [{
+				"mac": "a0:a0:a0:a0:a0:a0"
+			}],
+			"radio_table_stats": [
+				{"name": "wifi0", "channel": 1},
+				{"name": "wifi1", "channel": 44}
+			],
+			"radio_table": [
+				{"name": "wifi0", "radio": "ng"},
+				{"name": "wifi1", "radio": "na"}
+			],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_channels_in_use{band="2.4GHz",site="Default"} 2`),
+				regexp.MustCompile(`unifi_site_channels_in_use{band="5GHz",site="Default"} 3`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one mesh AP and one wired AP, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Mesh",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {
+				"type": "wireless",
+				"signal": -62,
+				"uplink_mac": "ab:ad:1d:ea:ab:ad"
+			}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "Wired",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"uplink": {
+				"type": "wire"
+			}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_mesh_uplink_rssi_dbm{device_mac="de:ad:be:ef:de:ad",site="Default",uplink_mac="ab:ad:1d:ea:ab:ad"} -62\n# HELP`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with country and one without, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "US",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"country_code": 840
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "Unknown",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`# TYPE unifi_devices_country_info gauge\nunifi_devices_country_info{country="840",device_mac="de:ad:be:ef:de:ad",site="Default"} 1\n# HELP`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "devices with LEDs on, off, default, and unreported, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "On",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"led_override": "on"
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "Off",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"led_override": "off"
+		},
+		{
+			"_id": "ghi",
+			"inform_ip": "192.168.1.3",
+			"name": "Default",
+			"ethernet_table": [{
+				"mac": "00:11:22:33:44:55"
+			}],
+			"stat": {},
+			"led_override": "default"
+		},
+		{
+			"_id": "jkl",
+			"inform_ip": "192.168.1.4",
+			"name": "Unknown",
+			"ethernet_table": [{
+				"mac": "66:77:88:99:aa:bb"
+			}],
+			"stat": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`# TYPE unifi_devices_led_enabled gauge\nunifi_devices_led_enabled{device_mac="00:11:22:33:44:55",site="Default"} 1\nunifi_devices_led_enabled{device_mac="ab:ad:1d:ea:ab:ad",site="Default"} 0\nunifi_devices_led_enabled{device_mac="de:ad:be:ef:de:ad",site="Default"} 1\n# HELP`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with model, version, and serial, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"model": "U7PG2",
+			"version": "3.7.58.6385",
+			"serial": "DEADBEEFDEAD"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_info{anon_id="",hardware_revision="",id="abc",mac="de:ad:be:ef:de:ad",model="U7PG2",name="ABC",serial="DEADBEEFDEAD",site="Default",version="3.7.58.6385"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one seen and one never seen device, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"last_seen": 1500000000
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`# TYPE unifi_devices_last_seen_timestamp_seconds gauge\nunifi_devices_last_seen_timestamp_seconds{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 1.5e\+09\n# HELP`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one connected and one disconnected device, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"state": 1
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"state": 0
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_state{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 1`),
+				regexp.MustCompile(`unifi_devices_state{id="def",mac="ab:ad:1d:ea:ab:ad",name="DEF",site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "devices provisioning and connected, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"state": 5
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"state": 5
+		},
+		{
+			"_id": "ghi",
+			"inform_ip": "192.168.1.3",
+			"name": "GHI",
+			"ethernet_table": [{
+				"mac": "00:11:22:33:44:55"
+			}],
+			"stat": {},
+			"state": 1
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_pending_provision{site="Default"} 2\n`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with hardware revision and anonymized ID, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"model": "U7PG2",
+			"version": "3.7.58.6385",
+			"serial": "DEADBEEFDEAD",
+			"board_rev": 18,
+			"anon_id": "f00b4r"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_info{anon_id="f00b4r",hardware_revision="18",id="abc",mac="de:ad:be:ef:de:ad",model="U7PG2",name="ABC",serial="DEADBEEFDEAD",site="Default",version="3.7.58.6385"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one device with user and guest wireless traffic, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {
+				"user-rx_bytes": 800,
+				"user-tx_bytes": 400,
+				"user-rx_packets": 80,
+				"user-tx_packets": 40,
+				"guest-rx_bytes": 200,
+				"guest-tx_bytes": 100,
+				"guest-rx_packets": 20,
+				"guest-tx_packets": 10
+			}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_devices_wireless_received_bytes_user_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 800`),
+				regexp.MustCompile(`unifi_devices_wireless_transmitted_bytes_user_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 400`),
+				regexp.MustCompile(`unifi_devices_wireless_received_packets_user_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 80`),
+				regexp.MustCompile(`unifi_devices_wireless_transmitted_packets_user_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 40`),
+				regexp.MustCompile(`unifi_devices_wireless_received_bytes_guest_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 200`),
+				regexp.MustCompile(`unifi_devices_wireless_transmitted_bytes_guest_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 100`),
+				regexp.MustCompile(`unifi_devices_wireless_received_packets_guest_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 20`),
+				regexp.MustCompile(`unifi_devices_wireless_transmitted_packets_guest_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} 10`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one switch with port errors, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Switch",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"port_table": [
+				{
+					"port_idx": 1,
+					"name": "Port 1",
+					"up": true,
+					"rx_errors": 12,
+					"tx_errors": 3,
+					"rx_dropped": 5,
+					"tx_dropped": 2
+				},
+				{
+					"port_idx": 2,
+					"name": "Port 2",
+					"up": true
+				}
+			],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_device_port_rx_errors_total{device_mac="de:ad:be:ef:de:ad",port_idx="1",site="Default"} 12`),
+				regexp.MustCompile(`unifi_device_port_tx_errors_total{device_mac="de:ad:be:ef:de:ad",port_idx="1",site="Default"} 3`),
+				regexp.MustCompile(`unifi_device_port_dropped_total{device_mac="de:ad:be:ef:de:ad",port_idx="1",site="Default"} 7`),
+				regexp.MustCompile(`unifi_device_port_rx_errors_total{device_mac="de:ad:be:ef:de:ad",port_idx="2",site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one AP with clients, one AP without, a switch, and devices which are not APs or have no radios, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Busy",
+			"type": "uap",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"num_sta": 3
+			}],
+			"radio_table": [{
+				"name": "wifi0",
+				"radio": "ng"
+			}],
+			"stat": {}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "Empty",
+			"type": "uap",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"num_sta": 0
+			}],
+			"radio_table": [{
+				"name": "wifi0",
+				"radio": "ng"
+			}],
+			"stat": {}
+		},
+		{
+			"_id": "ghi",
+			"inform_ip": "192.168.1.3",
+			"name": "Switch",
+			"type": "usw",
+			"ethernet_table": [{
+				"mac": "a0:a0:a0:a0:a0:a0"
+			}],
+			"stat": {}
+		},
+		{
+			"_id": "jkl",
+			"inform_ip": "192.168.1.4",
+			"name": "Radioless",
+			"type": "uap",
+			"ethernet_table": [{
+				"mac": "b0:b0:b0:b0:b0:b0"
+			}],
+			"stat": {}
+		},
+		{
+			"_id": "mno",
+			"inform_ip": "192.168.1.5",
+			"name": "Untyped",
+			"ethernet_table": [{
+				"mac": "c0:c0:c0:c0:c0:c0"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"num_sta": 0
+			}],
+			"radio_table": [{
+				"name": "wifi0",
+				"radio": "ng"
+			}],
+			"stat": {}
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_aps_with_no_clients{site="Default"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testDeviceCollector(t, []byte(tt.input), tt.sites, tt.cfg)
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex.")
+			}
+		}
+	}
+}
+
+func TestDeviceCollectorSecondsSinceLastSeen(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	devices := strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Stale",
+			"last_seen": 1499999100,
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "Future",
+			"last_seen": 1500000005,
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+
+		if strings.HasSuffix(r.URL.Path, "stat/device") {
+			_, _ = w.Write([]byte(devices))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	out := testCollector(t, NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil))
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_devices_seconds_since_last_seen{id="abc",mac="de:ad:be:ef:de:ad",name="Stale",site="Default"} 900`),
+		regexp.MustCompile(`unifi_devices_seconds_since_last_seen{id="def",mac="ab:ad:1d:ea:ab:ad",name="Future",site="Default"} 0`),
+	}
+
+	for i, m := range matches {
+		t.Logf("[%02d] match: %s", i, m.String())
+
+		if !m.Match(out) {
+			t.Fatal("output failed to match regex")
+		}
+	}
+}
+
+func TestDeviceCollectorStateDuration(t *testing.T) {
+	device := func(state int) []byte {
+		return []byte(fmt.Sprintf(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"state": %d,
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`), state))
+	}
+
+	var (
+		now   time.Time
+		state int
+	)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+
+		if strings.HasSuffix(r.URL.Path, "stat/device") {
+			_, _ = w.Write(device(state))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	dc := NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil)
+
+	var tests = []struct {
+		desc  string
+		now   int64
+		state int
+		match *regexp.Regexp
+	}{
+		{
+			desc:  "enters provisioning",
+			now:   1500000000,
+			state: 5,
+			match: regexp.MustCompile(`unifi_devices_state_duration_seconds{device_mac="de:ad:be:ef:de:ad",site="Default",state="provisioning"} 0\n`),
+		},
+		{
+			desc:  "still provisioning",
+			now:   1500000060,
+			state: 5,
+			match: regexp.MustCompile(`unifi_devices_state_duration_seconds{device_mac="de:ad:be:ef:de:ad",site="Default",state="provisioning"} 60\n`),
+		},
+		{
+			desc:  "enters adopting",
+			now:   1500000090,
+			state: 7,
+			match: regexp.MustCompile(`unifi_devices_state_duration_seconds{device_mac="de:ad:be:ef:de:ad",site="Default",state="adopting"} 0\n`),
+		},
+		{
+			desc:  "still adopting",
+			now:   1500000120,
+			state: 7,
+			match: regexp.MustCompile(`unifi_devices_state_duration_seconds{device_mac="de:ad:be:ef:de:ad",site="Default",state="adopting"} 30\n`),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		now, state = time.Unix(tt.now, 0), tt.state
+		out := testCollector(t, dc)
+
+		if !tt.match.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", tt.match, string(out))
+		}
+	}
+
+	// Connected devices are not reported
+	now, state = time.Unix(1500000150, 0), 1
+	if out := testCollector(t, dc); bytes.Contains(out, []byte("unifi_devices_state_duration_seconds{")) {
+		t.Fatalf("unexpected state duration for connected device:\n%s", string(out))
+	}
+}
+
+func TestDeviceCollectorDeviceCountMismatch(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"self/sites": []byte(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "1",
+			"desc": "Default",
+			"name": "default",
+			"num_ap": 3
+		},
+		{
+			"_id": "2",
+			"desc": "Some Site",
+			"name": "abcdef",
+			"num_ap": 2
+		}
+	]
+}
+`)),
+		"stat/device": []byte(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"adopted": true,
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		},
+		{
+			"_id": "def",
+			"adopted": true,
+			"inform_ip": "192.168.1.2",
+			"name": "DEF",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`)),
+	})
+	defer done()
+
+	out := testCollector(t, NewDeviceCollector(c, []*unifi.Site{
+		{
+			Name:        "default",
+			Description: "Default",
+		},
+		{
+			Name:        "abcdef",
+			Description: "Some Site",
+		},
+	}, nil))
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_site_device_count_mismatch{site="Default"} 1`),
+		regexp.MustCompile(`unifi_site_device_count_mismatch{site="Some Site"} 0`),
+	}
+
+	for i, m := range matches {
+		t.Logf("[%02d] match: %s", i, m.String())
+
+		if !m.Match(out) {
+			t.Fatal("output failed to match regex")
+		}
+	}
+}
+
+func TestDeviceCollectorMonotonicCounters(t *testing.T) {
+	device := func(rxBytes int) []byte {
+		return []byte(fmt.Sprintf(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {
+				"rx_bytes": %d
+			},
+			"uplink": {}
+		}
+	]
+}
+`), rxBytes))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/device": device(100),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	dc := NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, &Config{
+		MonotonicCounters: true,
+	})
+
+	var tests = []struct {
+		desc    string
+		rxBytes int
+		want    int
+	}{
+		{
+			desc:    "first scrape",
+			rxBytes: 100,
+			want:    100,
+		},
+		{
+			desc:    "increment",
+			rxBytes: 150,
+			want:    150,
+		},
+		{
+			desc:    "reset",
+			rxBytes: 20,
+			want:    170,
+		},
+		{
+			desc:    "increment after reset",
+			rxBytes: 50,
+			want:    200,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		endpoints["stat/device"] = device(tt.rxBytes)
+		out := testCollector(t, dc)
+
+		m := regexp.MustCompile(fmt.Sprintf(`unifi_devices_wireless_received_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} %d\n`, tt.want))
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s", m)
+		}
+	}
+}
+
+func TestDeviceCollectorMonotonicUptime(t *testing.T) {
+	device := func(uptime int) []byte {
+		return []byte(fmt.Sprintf(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"uplink": {},
+			"uptime": %d
+		}
+	]
+}
+`), uptime))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/device": device(0),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	dc := NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, &Config{
+		MonotonicUptime: true,
+	})
+
+	var tests = []struct {
+		desc   string
+		uptime int
+		want   int
+	}{
+		{
+			desc:   "first scrape",
+			uptime: 3600,
+			want:   3600,
+		},
+		{
+			desc:   "uptime increases",
+			uptime: 3660,
+			want:   3660,
+		},
+		{
+			desc:   "device reboots",
+			uptime: 30,
+			want:   3690,
+		},
+		{
+			desc:   "uptime increases after reboot",
+			uptime: 90,
+			want:   3750,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		endpoints["stat/device"] = device(tt.uptime)
+		out := testCollector(t, dc)
+
+		m := regexp.MustCompile(fmt.Sprintf(`unifi_devices_uptime_seconds_total{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} %d\n`, tt.want))
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s", m)
+		}
+	}
+}
+
+func TestDeviceCollectorRadioChannelChanges(t *testing.T) {
+	device := func(channel int) []byte {
+		return []byte(fmt.Sprintf(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi1",
+				"channel": %d
+			}],
+			"radio_table": [{
+				"name": "wifi1",
+				"radio": "na"
+			}],
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`), channel))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/device": device(36),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	dc := NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil)
+
+	var tests = []struct {
+		desc    string
+		channel int
+		want    int
+	}{
+		{
+			desc:    "first scrape",
+			channel: 36,
+			want:    0,
+		},
+		{
+			desc:    "channel changes",
+			channel: 149,
+			want:    1,
+		},
+		{
+			desc:    "channel unchanged",
+			channel: 149,
+			want:    1,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		endpoints["stat/device"] = device(tt.channel)
+		out := testCollector(t, dc)
+
+		m := regexp.MustCompile(fmt.Sprintf(`unifi_devices_radio_channel_changes_total{id="abc",interface="wifi1",mac="de:ad:be:ef:de:ad",name="ABC",radio="5GHz",site="Default"} %d\n`, tt.want))
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s", m)
+		}
+	}
+}
+
+func TestDeviceCollectorSatisfactionAverage(t *testing.T) {
+	device := func(satisfaction int) []byte {
+		return []byte(fmt.Sprintf(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"satisfaction": %d,
+			"stat": {},
+			"uplink": {}
+		}
+	]
+}
+`), satisfaction))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/device": device(100),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	dc := NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, &Config{SatisfactionWindow: 3})
+
+	var tests = []struct {
+		satisfaction int
+		ratio        string
+		avg          string
+	}{
+		{satisfaction: 100, ratio: "1", avg: "1"},
+		{satisfaction: 50, ratio: "0.5", avg: "0.75"},
+		{satisfaction: 75, ratio: "0.75", avg: "0.75"},
+		{satisfaction: 25, ratio: "0.25", avg: "0.5"},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] satisfaction %d", i, tt.satisfaction)
+
+		endpoints["stat/device"] = device(tt.satisfaction)
+		out := testCollector(t, dc)
+
+		matches := []*regexp.Regexp{
+			regexp.MustCompile(fmt.Sprintf(`unifi_devices_satisfaction_ratio{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} %s\n`, tt.ratio)),
+			regexp.MustCompile(fmt.Sprintf(`unifi_devices_satisfaction_ratio_avg{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} %s\n`, tt.avg)),
+		}
+
+		for _, m := range matches {
+			if !m.Match(out) {
+				t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+			}
+		}
+	}
+}
+
+func TestDeviceCollectorMemoryTrend(t *testing.T) {
+	device := func(used int) []byte {
+		return []byte(fmt.Sprintf(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "ABC",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"stat": {},
+			"sys_stats": {
+				"mem_total": 1024,
+				"mem_used": %d
+			}
+		}
+	]
+}
+`), used))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/device": device(256),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	dc := NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, &Config{MemoryTrendWindow: 3})
+
+	var tests = []struct {
+		used  int
+		trend string
+	}{
+		{used: 256, trend: "0"},
+		{used: 512, trend: "0.25"},
+		{used: 768, trend: "0.25"},
+		{used: 1024, trend: "0.25"},
+		{used: 1024, trend: "0.125"},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] memory used %d", i, tt.used)
+
+		endpoints["stat/device"] = device(tt.used)
+		out := testCollector(t, dc)
+
+		m := regexp.MustCompile(fmt.Sprintf(`unifi_devices_memory_trend_ratio{id="abc",mac="de:ad:be:ef:de:ad",name="ABC",site="Default"} %s\n`, tt.trend))
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+		}
+	}
+}
+
+func TestDeviceCollectorSysInfoUnavailable(t *testing.T) {
+	// sysinfo cannot be decoded, but only the metrics which depend upon
+	// the UniFi Controller's time should be missing
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/sysinfo": []byte(`{`),
+		"stat/device":  testExporterEndpoints["stat/device"],
+	})
+	defer done()
+
+	out := testCollector(t, NewDeviceCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil))
+
+	m := regexp.MustCompile(`unifi_devices_adopted{site="Default"} 1\n`)
+	if !m.Match(out) {
+		t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+	}
+
+	if bytes.Contains(out, []byte("unifi_devices_seconds_since_last_seen")) {
+		t.Fatalf("unexpected last seen series without sysinfo:\n%s", string(out))
+	}
+}
+
+func TestDeviceCollectorWirelessDeviceTypes(t *testing.T) {
+	// The switch reports spurious wireless fields which must be ignored
+	input := []byte(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "AP",
+			"type": "uap",
+			"ethernet_table": [{
+				"mac": "de:ad:be:ef:de:ad"
+			}],
+			"radio_table": [{
+				"name": "wifi0",
+				"radio": "ng"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"channel": 6,
+				"num_sta": 2
+			}],
+			"stat": {
+				"rx_bytes": 10
+			},
+			"uplink": {}
+		},
+		{
+			"_id": "def",
+			"inform_ip": "192.168.1.2",
+			"name": "Switch",
+			"type": "usw",
+			"ethernet_table": [{
+				"mac": "ab:ad:1d:ea:ab:ad"
+			}],
+			"radio_table": [{
+				"name": "wifi0",
+				"radio": "ng"
+			}],
+			"radio_table_stats": [{
+				"name": "wifi0",
+				"channel": 11,
+				"num_sta": 1
+			}],
+			"stat": {
+				"rx_bytes": 20
+			},
+			"uplink": {}
+		}
+	]
+}
+`))
+
+	sites := []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}
+
+	var tests = []struct {
+		desc     string
+		cfg      *Config
+		switches bool
+	}{
+		{
+			desc: "default types",
+		},
+		{
+			desc:     "switches included",
+			cfg:      &Config{WirelessDeviceTypes: []string{"uap", "usw"}},
+			switches: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testDeviceCollector(t, input, sites, tt.cfg)
+
+		ap := regexp.MustCompile(`unifi_devices_wireless_received_bytes_total{id="abc",mac="de:ad:be:ef:de:ad",name="AP",site="Default"} 10`)
+		if !ap.Match(out) {
+			t.Fatalf("output failed to match regex: %s", ap)
+		}
+
+		for _, name := range []string{
+			"unifi_devices_wireless_received_bytes_total",
+			"unifi_devices_stations",
+			"unifi_devices_radio_channel_changes_total",
+		} {
+			m := regexp.MustCompile(name + `{id="def",`)
+			if want, got := tt.switches, m.Match(out); want != got {
+				t.Fatalf("unexpected wireless metric %q for switch:\n- want: %v\n-  got: %v",
+					name, want, got)
+			}
+		}
+
+		channels := "1"
+		if tt.switches {
+			channels = "2"
+		}
+
+		m := regexp.MustCompile(`unifi_site_channels_in_use{band="2.4GHz",site="Default"} ` + channels + `\n`)
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s", m)
 		}
 	}
 }
 
-func testDeviceCollector(t *testing.T, input []byte, sites []*unifi.Site) []byte {
+func testDeviceCollector(t *testing.T, input []byte, sites []*unifi.Site, cfg *Config) []byte {
 	c, done := testUniFiClient(t, input)
 	defer done()
 
 	collector := NewDeviceCollector(
 		c,
 		sites,
+		cfg,
 	)
 
 	return testCollector(t, collector)
@@ -0,0 +1,177 @@
+package unifiexporter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dpiCategories maps the numeric DPI category IDs used by the UniFi Controller
+// to human-readable names.  Each name must be unique, so that the series of
+// distinct categories are never merged.
+var dpiCategories = map[int]string{
+	0:   "Instant messengers",
+	1:   "Peer-to-peer networks",
+	3:   "File sharing services and tools",
+	4:   "Media streaming services",
+	5:   "Mail and collaboration tools",
+	6:   "VoIP services",
+	7:   "Database tools",
+	8:   "Online games",
+	9:   "Management protocols",
+	10:  "Remote access terminals",
+	11:  "Bypass proxies and tunnels",
+	12:  "Stock market",
+	13:  "Web",
+	14:  "Security update",
+	15:  "Web IM",
+	17:  "Business",
+	18:  "Network protocols",
+	23:  "Private protocol",
+	24:  "Social network",
+	255: "Unknown",
+}
+
+// dpiCategoryLabel returns the name of DPI category id, or the ID itself if
+// its name is not known.
+func dpiCategoryLabel(id int) string {
+	if name, ok := dpiCategories[id]; ok {
+		return name
+	}
+
+	return strconv.Itoa(id)
+}
+
+// A DPICollector is a Prometheus collector for metrics regarding Ubiquiti
+// UniFi deep packet inspection (DPI) statistics.
+type DPICollector struct {
+	ReceivedBytes    *prometheus.Desc
+	TransmittedBytes *prometheus.Desc
+
+	c     *unifi.Client
+	sites []*unifi.Site
+
+	counters  *counterTracker
+	endpoints *endpointTracker
+	timeout   time.Duration
+	logger    *errorLogger
+}
+
+// Verify that the Exporter implements the collector interface.
+var _ collector = &DPICollector{}
+
+// NewDPICollector creates a new DPICollector which collects metrics for
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewDPICollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *DPICollector {
+	const (
+		subsystem = "dpi"
+	)
+
+	var (
+		labelsDPI = []string{"site", "category", "application"}
+	)
+
+	return &DPICollector{
+		ReceivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "received_bytes_total"),
+			"Number of bytes received by each application, as classified by deep packet inspection",
+			labelsDPI,
+			nil,
+		),
+
+		TransmittedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "transmitted_bytes_total"),
+			"Number of bytes transmitted by each application, as classified by deep packet inspection",
+			labelsDPI,
+			nil,
+		),
+
+		c:     c,
+		sites: sites,
+
+		counters:  cfg.counterTracker(),
+		endpoints: cfg.endpointTracker(),
+		timeout:   cfg.orDefault().ScrapeTimeout,
+		logger:    cfg.orDefault().logger,
+	}
+}
+
+// collect begins a metrics collection task for all metrics related to UniFi
+// DPI statistics.
+func (c *DPICollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	return forEachSite(c.sites, 1, func(s *unifi.Site) (*prometheus.Desc, error) {
+		stats, err := c.c.SiteDPIContext(ctx, s.Name)
+		if err != nil {
+			return c.ReceivedBytes, &siteError{site: s, err: err}
+		}
+		c.endpoints.success(endpointDPI)
+
+		c.collectDPIBytes(ch, s.Description, stats)
+		return nil, nil
+	})
+}
+
+// collectDPIBytes collects the number of bytes received and transmitted by
+// each application.  Categories are labeled by name where it is known, but
+// applications are labeled by their numeric ID, since the set of
+// applications varies between UniFi Controller versions.
+func (c *DPICollector) collectDPIBytes(ch chan<- prometheus.Metric, siteLabel string, stats []*unifi.DPIStat) {
+	for _, st := range stats {
+		labels := []string{
+			siteLabel,
+			dpiCategoryLabel(st.Category),
+			strconv.Itoa(st.Application),
+		}
+
+		ch <- c.counter(c.ReceivedBytes, float64(st.RXBytes), labels...)
+		ch <- c.counter(c.TransmittedBytes, float64(st.TXBytes), labels...)
+	}
+}
+
+// counter creates a counter metric for desc with value v, which is adjusted
+// for counter resets if c is configured to expose monotonic counters.
+func (c *DPICollector) counter(desc *prometheus.Desc, v float64, labels ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(
+		desc,
+		prometheus.CounterValue,
+		c.counters.value(desc, v, labels...),
+		labels...,
+	)
+}
+
+// Describe sends the descriptors of each metric over to the provided channel.
+// The corresponding metric values are sent separately.
+func (c *DPICollector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.ReceivedBytes,
+		c.TransmittedBytes,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect is the same as CollectError, but ignores any errors which occur.
+// Collect exists to satisfy the prometheus.Collector interface.
+func (c *DPICollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.CollectError(ch)
+}
+
+// CollectError sends the metric values for each metric pertaining to UniFi
+// DPI statistics over to the provided prometheus Metric channel, returning
+// any errors which occur.
+func (c *DPICollector) CollectError(ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		c.logger.Printf("[ERROR] failed collecting DPI metric %v: %v", desc, err)
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return err
+	}
+
+	return nil
+}
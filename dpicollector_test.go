@@ -0,0 +1,114 @@
+package unifiexporter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/unifi"
+)
+
+func TestDPICollector(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		input   string
+		sites   []*unifi.Site
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "DPI disabled, one site",
+			input: strings.TrimSpace(`
+{
+	"data": []
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`^$`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "known and unknown categories, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"by_app": [
+				{
+					"app": 5,
+					"cat": 4,
+					"rx_bytes": 1000,
+					"tx_bytes": 100
+				},
+				{
+					"app": 12,
+					"cat": 13,
+					"rx_bytes": 2000,
+					"tx_bytes": 200
+				},
+				{
+					"app": 7,
+					"cat": 99,
+					"rx_bytes": 3000,
+					"tx_bytes": 300
+				}
+			]
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_dpi_received_bytes_total{application="5",category="Media streaming services",site="Default"} 1000`),
+				regexp.MustCompile(`unifi_dpi_transmitted_bytes_total{application="5",category="Media streaming services",site="Default"} 100`),
+				regexp.MustCompile(`unifi_dpi_received_bytes_total{application="12",category="Web",site="Default"} 2000`),
+				regexp.MustCompile(`unifi_dpi_transmitted_bytes_total{application="12",category="Web",site="Default"} 200`),
+				regexp.MustCompile(`unifi_dpi_received_bytes_total{application="7",category="99",site="Default"} 3000`),
+				regexp.MustCompile(`unifi_dpi_transmitted_bytes_total{application="7",category="99",site="Default"} 300`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testDPICollector(t, []byte(tt.input), tt.sites)
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex")
+			}
+		}
+	}
+}
+
+func TestDPICategoryLabelsUnique(t *testing.T) {
+	seen := make(map[string]int)
+	for id, name := range dpiCategories {
+		if other, ok := seen[name]; ok {
+			t.Fatalf("DPI categories %d and %d share name %q", id, other, name)
+		}
+		seen[name] = id
+	}
+}
+
+func testDPICollector(t *testing.T, input []byte, sites []*unifi.Site) []byte {
+	c, done := testUniFiClient(t, input)
+	defer done()
+
+	collector := NewDPICollector(
+		c,
+		sites,
+		nil,
+	)
+
+	return testCollector(t, collector)
+}
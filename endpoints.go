@@ -0,0 +1,70 @@
+package unifiexporter
+
+import (
+	"sync"
+	"time"
+)
+
+// UniFi Controller API endpoints tracked by an endpointTracker.
+const (
+	endpointAlarms       = "list/alarm"
+	endpointDPI          = "stat/sitedpi"
+	endpointDevices      = "stat/device"
+	endpointEvents       = "stat/event"
+	endpointGuests       = "stat/guest"
+	endpointHealth       = "stat/health"
+	endpointKnownClients = "list/user"
+	endpointSites        = "self/sites"
+	endpointSpeedTest    = "cmd/devmgr"
+	endpointStations     = "stat/sta"
+	endpointSysInfo      = "stat/sysinfo"
+)
+
+// An endpointTracker tracks the last time each UniFi Controller API endpoint
+// was successfully queried.
+//
+// A nil *endpointTracker tracks nothing.
+type endpointTracker struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+
+	// now is used to determine the time of a successful query.
+	now func() time.Time
+}
+
+// newEndpointTracker creates an empty endpointTracker.
+func newEndpointTracker() *endpointTracker {
+	return &endpointTracker{
+		last: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// success records a successful query of endpoint.
+func (t *endpointTracker) success(endpoint string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last[endpoint] = t.now()
+}
+
+// snapshot returns a copy of the last success time for each endpoint.
+func (t *endpointTracker) snapshot() map[string]time.Time {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last := make(map[string]time.Time, len(t.last))
+	for k, v := range t.last {
+		last[k] = v
+	}
+
+	return last
+}
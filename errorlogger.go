@@ -0,0 +1,119 @@
+package unifiexporter
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultErrorLogInterval is the default interval used to suppress identical
+// error messages.
+const defaultErrorLogInterval = 5 * time.Minute
+
+// An errorLogger logs error messages, suppressing any identical message which
+// is logged again within an interval of the time it was last logged.  When a
+// message is next logged or flushed, the number of suppressed messages is
+// included.
+//
+// A nil *errorLogger logs all messages using the log package.
+type errorLogger struct {
+	mu       sync.Mutex
+	interval time.Duration
+	seen     map[string]*loggedError
+
+	now    func() time.Time
+	printf func(format string, v ...interface{})
+}
+
+// A loggedError tracks when a message was last logged, and how many times it
+// has been suppressed since.
+type loggedError struct {
+	last       time.Time
+	suppressed int
+}
+
+// newErrorLogger creates an errorLogger which suppresses identical messages
+// within interval.  If interval is zero, defaultErrorLogInterval is used.
+func newErrorLogger(interval time.Duration) *errorLogger {
+	if interval == 0 {
+		interval = defaultErrorLogInterval
+	}
+
+	return &errorLogger{
+		interval: interval,
+		seen:     make(map[string]*loggedError),
+
+		now:    time.Now,
+		printf: log.Printf,
+	}
+}
+
+// Printf formats a message and logs it, unless an identical message was
+// logged within l's interval.
+func (l *errorLogger) Printf(format string, v ...interface{}) {
+	if l == nil {
+		log.Printf(format, v...)
+		return
+	}
+
+	msg := fmt.Sprintf(format, v...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	if e, ok := l.seen[msg]; ok {
+		if now.Sub(e.last) < l.interval {
+			e.suppressed++
+			return
+		}
+
+		if e.suppressed > 0 {
+			l.printf("%s (suppressed %d identical messages)", msg, e.suppressed)
+			l.seen[msg] = &loggedError{last: now}
+			return
+		}
+	}
+
+	l.printf("%s", msg)
+	l.seen[msg] = &loggedError{last: now}
+}
+
+// flush logs the number of times each message was suppressed, for messages
+// which were last logged outside of l's interval, so that suppressed messages
+// are reported even if they do not occur again.
+func (l *errorLogger) flush() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for msg, e := range l.seen {
+		if e.suppressed == 0 || now.Sub(e.last) < l.interval {
+			continue
+		}
+
+		l.printf("%s (suppressed %d identical messages)", msg, e.suppressed)
+		l.seen[msg] = &loggedError{last: now}
+	}
+
+	l.prune(now)
+}
+
+// prune removes messages which were last logged outside of l's interval, and
+// which have not been suppressed since.
+//
+// prune must be called with l's mutex locked.
+func (l *errorLogger) prune(now time.Time) {
+	for msg, e := range l.seen {
+		if e.suppressed == 0 && now.Sub(e.last) >= l.interval {
+			delete(l.seen, msg)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package unifiexporter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorLogger(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+
+	var tests = []struct {
+		desc   string
+		offset time.Duration
+		msg    string
+		logged []string
+	}{
+		{
+			desc:   "first error",
+			msg:    "controller down",
+			logged: []string{"controller down"},
+		},
+		{
+			desc:   "identical error within interval",
+			offset: 10 * time.Second,
+			msg:    "controller down",
+			logged: []string{"controller down"},
+		},
+		{
+			desc:   "second identical error within interval",
+			offset: 20 * time.Second,
+			msg:    "controller down",
+			logged: []string{"controller down"},
+		},
+		{
+			desc:   "different error within interval",
+			offset: 30 * time.Second,
+			msg:    "bad credentials",
+			logged: []string{"controller down", "bad credentials"},
+		},
+		{
+			desc:   "identical error after interval",
+			offset: 90 * time.Second,
+			msg:    "controller down",
+			logged: []string{
+				"controller down",
+				"bad credentials",
+				"controller down (suppressed 2 identical messages)",
+			},
+		},
+	}
+
+	var logged []string
+	var now time.Time
+
+	l := newErrorLogger(time.Minute)
+	l.now = func() time.Time { return now }
+	l.printf = func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		now = start.Add(tt.offset)
+		l.Printf("%s", tt.msg)
+
+		if want, got := tt.logged, logged; !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected logged messages:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+	}
+}
+
+func TestErrorLoggerFlush(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+
+	var tests = []struct {
+		desc   string
+		offset time.Duration
+		msg    string
+		logged []string
+	}{
+		{
+			desc:   "first error",
+			msg:    "controller down",
+			logged: []string{"controller down"},
+		},
+		{
+			desc:   "identical error within interval",
+			offset: 10 * time.Second,
+			msg:    "controller down",
+			logged: []string{"controller down"},
+		},
+		{
+			desc:   "flush within interval",
+			offset: 30 * time.Second,
+			logged: []string{"controller down"},
+		},
+		{
+			desc:   "flush after interval",
+			offset: 90 * time.Second,
+			logged: []string{
+				"controller down",
+				"controller down (suppressed 1 identical messages)",
+			},
+		},
+		{
+			desc:   "flush after suppressed messages are reported",
+			offset: 180 * time.Second,
+			logged: []string{
+				"controller down",
+				"controller down (suppressed 1 identical messages)",
+			},
+		},
+		{
+			desc:   "identical error after flush",
+			offset: 190 * time.Second,
+			msg:    "controller down",
+			logged: []string{
+				"controller down",
+				"controller down (suppressed 1 identical messages)",
+				"controller down",
+			},
+		},
+	}
+
+	var logged []string
+	var now time.Time
+
+	l := newErrorLogger(time.Minute)
+	l.now = func() time.Time { return now }
+	l.printf = func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		now = start.Add(tt.offset)
+		if tt.msg != "" {
+			l.Printf("%s", tt.msg)
+		} else {
+			l.flush()
+		}
+
+		if want, got := tt.logged, logged; !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected logged messages:\n- want: %v\n-  got: %v",
+				want, got)
+		}
+	}
+
+	// A nil errorLogger has nothing to flush
+	var nl *errorLogger
+	nl.flush()
+}
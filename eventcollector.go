@@ -0,0 +1,130 @@
+package unifiexporter
+
+import (
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stationKickReasons maps the keys of UniFi Controller events which record a
+// station being steered or kicked from an access point to the reason label
+// reported for them.
+var stationKickReasons = map[string]string{
+	"EVT_WU_BandSteer":   "band_steering",
+	"EVT_WU_MinRSSIKick": "minimum_rssi",
+}
+
+// An EventCollector is a Prometheus collector for metrics regarding Ubiquiti
+// UniFi events.
+type EventCollector struct {
+	StationsKicked *prometheus.Desc
+
+	c     *unifi.Client
+	sites []*unifi.Site
+
+	events    *eventTracker
+	endpoints *endpointTracker
+	timeout   time.Duration
+	logger    *errorLogger
+}
+
+// Verify that the Exporter implements the collector interface.
+var _ collector = &EventCollector{}
+
+// NewEventCollector creates a new EventCollector which collects metrics for
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewEventCollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *EventCollector {
+	const (
+		subsystem = "stations"
+	)
+
+	var (
+		labelsKick = []string{"site", "reason"}
+	)
+
+	return &EventCollector{
+		StationsKicked: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "kicked_total"),
+			"Number of stations which were steered to another band or kicked for a low signal, grouped by reason",
+			labelsKick,
+			nil,
+		),
+
+		c:     c,
+		sites: sites,
+
+		events:    cfg.eventTracker(),
+		endpoints: cfg.endpointTracker(),
+		timeout:   cfg.orDefault().ScrapeTimeout,
+		logger:    cfg.orDefault().logger,
+	}
+}
+
+// collect begins a metrics collection task for all metrics related to UniFi
+// events.
+func (c *EventCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	return forEachSite(c.sites, 1, func(s *unifi.Site) (*prometheus.Desc, error) {
+		events, err := c.c.EventsContext(ctx, s.Name)
+		if err != nil {
+			return c.StationsKicked, &siteError{site: s, err: err}
+		}
+		c.endpoints.success(endpointEvents)
+
+		c.collectStationsKicked(ch, s.Description, events)
+		return nil, nil
+	})
+}
+
+// collectStationsKicked collects the number of stations steered or kicked by
+// access points, as recorded by events.  The UniFi Controller only reports
+// recent events, so each event is counted once when it first appears.
+func (c *EventCollector) collectStationsKicked(ch chan<- prometheus.Metric, siteLabel string, events []*unifi.Event) {
+	kicked := c.events.count(c.StationsKicked, events, func(e *unifi.Event) string {
+		return stationKickReasons[e.Key]
+	}, siteLabel)
+
+	for reason, n := range kicked {
+		ch <- prometheus.MustNewConstMetric(
+			c.StationsKicked,
+			prometheus.CounterValue,
+			n,
+			siteLabel,
+			reason,
+		)
+	}
+}
+
+// Describe sends the descriptors of each metric over to the provided channel.
+// The corresponding metric values are sent separately.
+func (c *EventCollector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.StationsKicked,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect is the same as CollectError, but ignores any errors which occur.
+// Collect exists to satisfy the prometheus.Collector interface.
+func (c *EventCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.CollectError(ch)
+}
+
+// CollectError sends the metric values for each metric pertaining to UniFi
+// events over to the provided prometheus Metric channel, returning any errors
+// which occur.
+func (c *EventCollector) CollectError(ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		c.logger.Printf("[ERROR] failed collecting event metric %v: %v", desc, err)
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package unifiexporter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/unifi"
+)
+
+func TestEventCollector(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		input   string
+		sites   []*unifi.Site
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "kicked stations, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"datetime": "2017-01-01T00:00:00Z",
+			"key": "EVT_WU_BandSteer",
+			"msg": "User was steered to 5GHz",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "def",
+			"datetime": "2017-01-01T00:00:01Z",
+			"key": "EVT_WU_BandSteer",
+			"msg": "User was steered to 5GHz",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "ghi",
+			"datetime": "2017-01-01T00:00:02Z",
+			"key": "EVT_WU_MinRSSIKick",
+			"msg": "User was kicked for a low signal",
+			"subsystem": "wlan"
+		},
+		{
+			"_id": "jkl",
+			"datetime": "2017-01-01T00:00:03Z",
+			"key": "EVT_WU_Connected",
+			"msg": "User has connected",
+			"subsystem": "wlan"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_kicked_total{reason="band_steering",site="Default"} 2`),
+				regexp.MustCompile(`unifi_stations_kicked_total{reason="minimum_rssi",site="Default"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testEventCollector(t, []byte(tt.input), tt.sites)
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex")
+			}
+		}
+	}
+}
+
+func TestEventCollectorNoKicks(t *testing.T) {
+	out := testEventCollector(t, []byte(`{"data":[{"_id":"abc","datetime":"2017-01-01T00:00:00Z","key":"EVT_WU_Connected"}]}`), []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}})
+
+	if bytes.Contains(out, []byte("unifi_stations_kicked_total")) {
+		t.Fatalf("unexpected kicked stations series:\n%s", string(out))
+	}
+}
+
+func TestEventCollectorCountsEachEventOnce(t *testing.T) {
+	input := `{"data":[{"_id":"abc","datetime":"2017-01-01T00:00:00Z","key":"EVT_WU_BandSteer"}]}`
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_, _ = w.Write([]byte(input))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	collector := NewEventCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil)
+
+	var tests = []struct {
+		desc  string
+		input string
+		match *regexp.Regexp
+	}{
+		{
+			desc:  "first event",
+			input: input,
+			match: regexp.MustCompile(`unifi_stations_kicked_total{reason="band_steering",site="Default"} 1\n`),
+		},
+		{
+			desc:  "same event reported again",
+			input: input,
+			match: regexp.MustCompile(`unifi_stations_kicked_total{reason="band_steering",site="Default"} 1\n`),
+		},
+		{
+			desc:  "newer event reported",
+			input: `{"data":[{"_id":"def","datetime":"2017-01-01T00:01:00Z","key":"EVT_WU_BandSteer"},{"_id":"abc","datetime":"2017-01-01T00:00:00Z","key":"EVT_WU_BandSteer"}]}`,
+			match: regexp.MustCompile(`unifi_stations_kicked_total{reason="band_steering",site="Default"} 2\n`),
+		},
+		{
+			desc:  "events no longer reported",
+			input: `{"data":[]}`,
+			match: regexp.MustCompile(`unifi_stations_kicked_total{reason="band_steering",site="Default"} 2\n`),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		input = tt.input
+		out := testCollector(t, collector)
+
+		if !tt.match.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", tt.match, string(out))
+		}
+	}
+}
+
+func testEventCollector(t *testing.T, input []byte, sites []*unifi.Site) []byte {
+	c, done := testUniFiClient(t, input)
+	defer done()
+
+	collector := NewEventCollector(
+		c,
+		sites,
+		nil,
+	)
+
+	return testCollector(t, collector)
+}
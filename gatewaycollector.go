@@ -0,0 +1,263 @@
+package unifiexporter
+
+import (
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A GatewayCollector is a Prometheus collector for metrics regarding the
+// services provided by a Ubiquiti UniFi gateway, as reported by the health of
+// each site.
+type GatewayCollector struct {
+	DHCPLeases *prometheus.Desc
+	InternetUp *prometheus.Desc
+
+	SpeedTestDownloadMbps *prometheus.Desc
+	SpeedTestUploadMbps   *prometheus.Desc
+	SpeedTestLatencyMS    *prometheus.Desc
+	SpeedTestLastRun      *prometheus.Desc
+
+	WiFiExperienceRatio *prometheus.Desc
+
+	c     *unifi.Client
+	sites []*unifi.Site
+
+	endpoints *endpointTracker
+	timeout   time.Duration
+	logger    *errorLogger
+}
+
+// Verify that the Exporter implements the collector interface.
+var _ collector = &GatewayCollector{}
+
+// NewGatewayCollector creates a new GatewayCollector which collects metrics
+// for a specified site.  If cfg is nil, a default configuration is used.
+func NewGatewayCollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *GatewayCollector {
+	const (
+		subsystem = "gateway"
+	)
+
+	var (
+		labelsSiteOnly  = []string{"site"}
+		labelsSubsystem = []string{"site", "subsystem"}
+	)
+
+	return &GatewayCollector{
+		DHCPLeases: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "dhcp_leases"),
+			"Number of DHCP leases handed out by the gateway, for each subsystem which reports them",
+			labelsSubsystem,
+			nil,
+		),
+
+		InternetUp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "internet_up"),
+			"Whether the gateway is able to resolve and reach internet hosts (1) or not (0)",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SpeedTestDownloadMbps: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wan", "speedtest_download_mbps"),
+			"Download throughput measured by the most recent WAN speed test in megabits per second",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SpeedTestUploadMbps: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wan", "speedtest_upload_mbps"),
+			"Upload throughput measured by the most recent WAN speed test in megabits per second",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SpeedTestLatencyMS: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wan", "speedtest_latency_ms"),
+			"Latency measured by the most recent WAN speed test in milliseconds",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SpeedTestLastRun: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wan", "speedtest_last_run_timestamp_seconds"),
+			"Time the most recent WAN speed test was run since the UNIX epoch in seconds",
+			labelsSiteOnly,
+			nil,
+		),
+
+		WiFiExperienceRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "wifi_experience_ratio"),
+			"Site-wide WiFi experience score reported by the controller, as a ratio from 0 to 1",
+			labelsSiteOnly,
+			nil,
+		),
+
+		c:     c,
+		sites: sites,
+
+		endpoints: cfg.endpointTracker(),
+		timeout:   cfg.orDefault().ScrapeTimeout,
+		logger:    cfg.orDefault().logger,
+	}
+}
+
+// collect begins a metrics collection task for all metrics related to UniFi
+// gateways.
+func (c *GatewayCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	return forEachSite(c.sites, 1, func(s *unifi.Site) (*prometheus.Desc, error) {
+		health, err := c.c.HealthContext(ctx, s.Name)
+		if err != nil {
+			return c.DHCPLeases, &siteError{site: s, err: err}
+		}
+		c.endpoints.success(endpointHealth)
+
+		c.collectGatewayServices(ch, s.Description, health)
+		c.collectSiteWiFiExperience(ch, s.Description, health)
+
+		// Sites without a gateway and read-only accounts cannot retrieve
+		// speed test results, which should not prevent collecting the
+		// site's health
+		st, err := c.c.SpeedTestContext(ctx, s.Name)
+		if err != nil {
+			c.logger.Printf("[ERROR] failed retrieving UniFi Controller speed test for site %q: %v", s.Name, err)
+			return nil, nil
+		}
+		c.endpoints.success(endpointSpeedTest)
+
+		c.collectGatewaySpeedTest(ch, s.Description, st)
+		return nil, nil
+	})
+}
+
+// collectGatewayServices collects DHCP leases and internet health for a site's
+// gateway.  Metrics are only collected for subsystems which report them.
+func (c *GatewayCollector) collectGatewayServices(ch chan<- prometheus.Metric, siteLabel string, health []*unifi.Health) {
+	for _, h := range health {
+		switch h.Subsystem {
+		case unifi.HealthLAN, unifi.HealthWAN:
+			if h.DHCPLeases < 0 {
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				c.DHCPLeases,
+				prometheus.GaugeValue,
+				float64(h.DHCPLeases),
+				siteLabel,
+				h.Subsystem,
+			)
+		case unifi.HealthWWW:
+			// The controller determines internet health by resolving and
+			// reaching well-known hosts through the gateway
+			if h.Status == "" || h.Status == "unknown" {
+				continue
+			}
+
+			var up float64
+			if h.Status == "ok" {
+				up = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				c.InternetUp,
+				prometheus.GaugeValue,
+				up,
+				siteLabel,
+			)
+		}
+	}
+}
+
+// collectSiteWiFiExperience collects the site-wide WiFi experience score, for
+// controllers which report one in the health of the wlan subsystem.
+func (c *GatewayCollector) collectSiteWiFiExperience(ch chan<- prometheus.Metric, siteLabel string, health []*unifi.Health) {
+	for _, h := range health {
+		if h.Subsystem != unifi.HealthWLAN || h.Satisfaction < 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.WiFiExperienceRatio,
+			prometheus.GaugeValue,
+			float64(h.Satisfaction)/100,
+			siteLabel,
+		)
+	}
+}
+
+// collectGatewaySpeedTest collects the results of the most recent WAN speed
+// test run by a site's gateway, if one has been run.
+func (c *GatewayCollector) collectGatewaySpeedTest(ch chan<- prometheus.Metric, siteLabel string, st *unifi.SpeedTest) {
+	if st.RunDate.IsZero() {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.SpeedTestDownloadMbps,
+		prometheus.GaugeValue,
+		st.Download,
+		siteLabel,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.SpeedTestUploadMbps,
+		prometheus.GaugeValue,
+		st.Upload,
+		siteLabel,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.SpeedTestLatencyMS,
+		prometheus.GaugeValue,
+		float64(st.Latency/time.Millisecond),
+		siteLabel,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.SpeedTestLastRun,
+		prometheus.GaugeValue,
+		float64(st.RunDate.Unix()),
+		siteLabel,
+	)
+}
+
+// Describe sends the descriptors of each metric over to the provided channel.
+// The corresponding metric values are sent separately.
+func (c *GatewayCollector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.DHCPLeases,
+		c.InternetUp,
+
+		c.SpeedTestDownloadMbps,
+		c.SpeedTestUploadMbps,
+		c.SpeedTestLatencyMS,
+		c.SpeedTestLastRun,
+
+		c.WiFiExperienceRatio,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect is the same as CollectError, but ignores any errors which occur.
+// Collect exists to satisfy the prometheus.Collector interface.
+func (c *GatewayCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.CollectError(ch)
+}
+
+// CollectError sends the metric values for each metric pertaining to the global
+// cluster usage over to the provided prometheus Metric channel, returning any
+// errors which occur.
+func (c *GatewayCollector) CollectError(ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		c.logger.Printf("[ERROR] failed collecting gateway metric %v: %v", desc, err)
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return err
+	}
+
+	return nil
+}
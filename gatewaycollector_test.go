@@ -0,0 +1,217 @@
+package unifiexporter
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/unifi"
+)
+
+func TestGatewayCollector(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		input   string
+		sites   []*unifi.Site
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "gateway leases and internet up, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"subsystem": "wlan",
+			"status": "ok"
+		},
+		{
+			"subsystem": "lan",
+			"status": "ok",
+			"num_dhcp_lease": 42
+		},
+		{
+			"subsystem": "www",
+			"status": "ok"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_gateway_dhcp_leases{site="Default",subsystem="lan"} 42`),
+				regexp.MustCompile(`unifi_gateway_internet_up{site="Default"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "internet down, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"subsystem": "lan",
+			"status": "ok",
+			"num_dhcp_lease": 0
+		},
+		{
+			"subsystem": "www",
+			"status": "error"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_gateway_dhcp_leases{site="Default",subsystem="lan"} 0`),
+				regexp.MustCompile(`unifi_gateway_internet_up{site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "leases reported by both lan and wan, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"subsystem": "lan",
+			"status": "ok",
+			"num_dhcp_lease": 42
+		},
+		{
+			"subsystem": "wan",
+			"status": "ok",
+			"num_dhcp_lease": 1
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_gateway_dhcp_leases{site="Default",subsystem="lan"} 42`),
+				regexp.MustCompile(`unifi_gateway_dhcp_leases{site="Default",subsystem="wan"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "WiFi experience reported, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"subsystem": "wlan",
+			"status": "ok",
+			"satisfaction": 87
+		},
+		{
+			"subsystem": "www",
+			"status": "ok"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_wifi_experience_ratio{site="Default"} 0.87`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testGatewayCollector(t, []byte(tt.input), tt.sites)
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex")
+			}
+		}
+	}
+}
+
+func TestGatewayCollectorSpeedTest(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"cmd/devmgr": []byte(strings.TrimSpace(`
+{
+	"meta": {
+		"rc": "ok"
+	},
+	"data": [
+		{
+			"latency": 12,
+			"rundate": 1500000000,
+			"status_download": 2,
+			"status_upload": 2,
+			"xput_download": 94.5,
+			"xput_upload": 10.25
+		}
+	]
+}
+`)),
+	})
+	defer done()
+
+	out := testCollector(t, NewGatewayCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil))
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_wan_speedtest_download_mbps{site="Default"} 94.5`),
+		regexp.MustCompile(`unifi_wan_speedtest_upload_mbps{site="Default"} 10.25`),
+		regexp.MustCompile(`unifi_wan_speedtest_latency_ms{site="Default"} 12`),
+		regexp.MustCompile(`unifi_wan_speedtest_last_run_timestamp_seconds{site="Default"} 1.5e\+09`),
+	}
+
+	for i, m := range matches {
+		t.Logf("[%02d] match: %s", i, m.String())
+
+		if !m.Match(out) {
+			t.Fatal("output failed to match regex")
+		}
+	}
+}
+
+func TestGatewayCollectorSpeedTestUnavailable(t *testing.T) {
+	// The speed test command is rejected, but the site's health should
+	// still be collected
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/health": []byte(`{"data":[{"subsystem":"lan","status":"ok","num_dhcp_lease":42}]}`),
+		"cmd/devmgr":  []byte(`{"meta":{"rc":"error","msg":"api.err.NoPermission"},"data":[]}`),
+	})
+	defer done()
+
+	out := testCollector(t, NewGatewayCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil))
+
+	if want := []byte(`unifi_gateway_dhcp_leases{site="Default",subsystem="lan"} 42`); !bytes.Contains(out, want) {
+		t.Fatalf("output missing DHCP leases:\n%s", string(out))
+	}
+
+	if bytes.Contains(out, []byte("unifi_wan_speedtest")) {
+		t.Fatalf("unexpected speed test series:\n%s", string(out))
+	}
+}
+
+func testGatewayCollector(t *testing.T, input []byte, sites []*unifi.Site) []byte {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/health": input,
+	})
+	defer done()
+
+	return testCollector(t, NewGatewayCollector(c, sites, nil))
+}
@@ -0,0 +1,184 @@
+package unifiexporter
+
+import (
+	"time"
+
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A GuestCollector is a Prometheus collector for metrics regarding Ubiquiti
+// UniFi guest portal clients.
+type GuestCollector struct {
+	Guests          *prometheus.Desc
+	GuestsOverQuota *prometheus.Desc
+	GuestsByMethod  *prometheus.Desc
+
+	c     *unifi.Client
+	sites []*unifi.Site
+
+	endpoints *endpointTracker
+	timeout   time.Duration
+	logger    *errorLogger
+
+	// now is used to determine if a guest's authorization has ended.
+	now func() time.Time
+}
+
+// Verify that the Exporter implements the collector interface.
+var _ collector = &GuestCollector{}
+
+// NewGuestCollector creates a new GuestCollector which collects metrics for
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewGuestCollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *GuestCollector {
+	const (
+		subsystem = "guests"
+	)
+
+	var (
+		labelsSiteOnly = []string{"site"}
+	)
+
+	return &GuestCollector{
+		Guests: prometheus.NewDesc(
+			// Subsystem is used as name so we get "unifi_guests"
+			prometheus.BuildFQName(namespace, "", subsystem),
+			"Total number of guest portal authorizations which have not expired",
+			labelsSiteOnly,
+			nil,
+		),
+
+		GuestsOverQuota: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "over_quota"),
+			"Number of unexpired guests which have exceeded their data or time quota",
+			labelsSiteOnly,
+			nil,
+		),
+
+		GuestsByMethod: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "by_auth_method"),
+			"Number of unexpired guests, grouped by the method used to authorize them",
+			[]string{"site", "method"},
+			nil,
+		),
+
+		c:     c,
+		sites: sites,
+
+		endpoints: cfg.endpointTracker(),
+		timeout:   cfg.orDefault().ScrapeTimeout,
+		logger:    cfg.orDefault().logger,
+
+		now: time.Now,
+	}
+}
+
+// collect begins a metrics collection task for all metrics related to UniFi
+// guests.
+func (c *GuestCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	return forEachSite(c.sites, 1, func(s *unifi.Site) (*prometheus.Desc, error) {
+		guests, err := c.c.GuestsContext(ctx, s.Name)
+		if err != nil {
+			return c.Guests, &siteError{site: s, err: err}
+		}
+		c.endpoints.success(endpointGuests)
+
+		c.collectGuestQuotas(ch, s.Description, guests)
+		c.collectGuestMethods(ch, s.Description, guests)
+		return nil, nil
+	})
+}
+
+// collectGuestQuotas collects counts of guests, and guests which have exceeded
+// their data or time quota.
+func (c *GuestCollector) collectGuestQuotas(ch chan<- prometheus.Metric, siteLabel string, guests []*unifi.Guest) {
+	now := c.now()
+
+	var active, overQuota int
+	for _, g := range guests {
+		// Expired guests have already been deauthorized by the controller
+		if g.Expired {
+			continue
+		}
+		active++
+
+		overData := g.UsageQuota > 0 && g.Bytes > g.UsageQuota
+		overTime := !g.End.IsZero() && now.After(g.End)
+		if overData || overTime {
+			overQuota++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.Guests,
+		prometheus.GaugeValue,
+		float64(active),
+		siteLabel,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.GuestsOverQuota,
+		prometheus.GaugeValue,
+		float64(overQuota),
+		siteLabel,
+	)
+}
+
+// collectGuestMethods collects counts of unexpired guests by the method used
+// to authorize them, such as a voucher or payment.
+func (c *GuestCollector) collectGuestMethods(ch chan<- prometheus.Metric, siteLabel string, guests []*unifi.Guest) {
+	methods := make(map[string]int)
+	for _, g := range guests {
+		if g.Expired {
+			continue
+		}
+
+		methods[g.AuthorizedBy]++
+	}
+
+	for m, n := range methods {
+		ch <- prometheus.MustNewConstMetric(
+			c.GuestsByMethod,
+			prometheus.GaugeValue,
+			float64(n),
+			siteLabel,
+			m,
+		)
+	}
+}
+
+// Describe sends the descriptors of each metric over to the provided channel.
+// The corresponding metric values are sent separately.
+func (c *GuestCollector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.Guests,
+		c.GuestsOverQuota,
+		c.GuestsByMethod,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect is the same as CollectError, but ignores any errors which occur.
+// Collect exists to satisfy the prometheus.Collector interface.
+func (c *GuestCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.CollectError(ch)
+}
+
+// CollectError sends the metric values for each metric pertaining to the global
+// cluster usage over to the provided prometheus Metric channel, returning any
+// errors which occur.
+func (c *GuestCollector) CollectError(ch chan<- prometheus.Metric) error {
+	if desc, err := c.collect(ch); err != nil {
+		c.logger.Printf("[ERROR] failed collecting guest metric %v: %v", desc, err)
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return err
+	}
+
+	return nil
+}
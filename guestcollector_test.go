@@ -0,0 +1,163 @@
+package unifiexporter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/unifi"
+)
+
+func TestGuestCollector(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		input   string
+		sites   []*unifi.Site
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "no guests, one site",
+			input: strings.TrimSpace(`
+{
+	"data": []
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_guests{site="Default"} 0`),
+				regexp.MustCompile(`unifi_guests_over_quota{site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "guests over data and time quotas, and one with no end time, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"mac": "de:ad:be:ef:de:ad",
+			"bytes": 1024,
+			"qos_usage_quota": 1,
+			"start": 1000,
+			"end": 3000
+		},
+		{
+			"_id": "def",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"bytes": 2097152,
+			"qos_usage_quota": 1,
+			"start": 1000,
+			"end": 3000
+		},
+		{
+			"_id": "ghi",
+			"mac": "a0:a0:a0:a0:a0:a0",
+			"start": 500,
+			"end": 1500
+		},
+		{
+			"_id": "mno",
+			"mac": "c0:c0:c0:c0:c0:c0",
+			"start": 500
+		},
+		{
+			"_id": "jkl",
+			"mac": "b0:b0:b0:b0:b0:b0",
+			"bytes": 2097152,
+			"qos_usage_quota": 1,
+			"start": 500,
+			"end": 1500,
+			"expired": true
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_guests{site="Default"} 4`),
+				regexp.MustCompile(`unifi_guests_over_quota{site="Default"} 2`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "guests authorized by multiple methods, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"mac": "de:ad:be:ef:de:ad",
+			"authorized_by": "voucher",
+			"start": 1000,
+			"end": 3000
+		},
+		{
+			"_id": "def",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"authorized_by": "voucher",
+			"start": 1000,
+			"end": 3000
+		},
+		{
+			"_id": "ghi",
+			"mac": "a0:a0:a0:a0:a0:a0",
+			"authorized_by": "payment",
+			"start": 1000,
+			"end": 3000
+		},
+		{
+			"_id": "jkl",
+			"mac": "b0:b0:b0:b0:b0:b0",
+			"authorized_by": "password",
+			"start": 500,
+			"end": 1500,
+			"expired": true
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_guests_by_auth_method{method="payment",site="Default"} 1`),
+				regexp.MustCompile(`unifi_guests_by_auth_method{method="voucher",site="Default"} 2`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testGuestCollector(t, []byte(tt.input), tt.sites, time.Unix(2000, 0))
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex")
+			}
+		}
+	}
+}
+
+func testGuestCollector(t *testing.T, input []byte, sites []*unifi.Site, now time.Time) []byte {
+	c, done := testUniFiClient(t, input)
+	defer done()
+
+	collector := NewGuestCollector(
+		c,
+		sites,
+		nil,
+	)
+	collector.now = func() time.Time { return now }
+
+	return testCollector(t, collector)
+}
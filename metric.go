@@ -0,0 +1,61 @@
+package unifiexporter
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// withTimestamp returns a prometheus.Metric which exposes m with the explicit
+// timestamp t, rather than the time of the scrape.  If t is not after the
+// UNIX epoch, m is returned unmodified.
+func withTimestamp(m prometheus.Metric, t time.Time) prometheus.Metric {
+	if t.Unix() <= 0 {
+		return m
+	}
+
+	return &timestampedMetric{
+		Metric: m,
+		t:      t,
+	}
+}
+
+// A timestampedMetric is a prometheus.Metric with an explicit timestamp.
+type timestampedMetric struct {
+	prometheus.Metric
+	t time.Time
+}
+
+// Write implements prometheus.Metric.
+func (m *timestampedMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+
+	ms := m.t.UnixNano() / int64(time.Millisecond)
+	out.TimestampMs = &ms
+	return nil
+}
+
+// samples returns the number of samples m produces when exposed.  Histograms
+// and summaries produce a sample for each bucket or quantile, in addition to
+// their sum and count.
+func samples(m *dto.Metric) int {
+	switch {
+	case m.Histogram != nil:
+		n := len(m.Histogram.Bucket) + 2
+
+		// The +Inf bucket is implied if not present
+		if bs := m.Histogram.Bucket; len(bs) == 0 || !math.IsInf(bs[len(bs)-1].GetUpperBound(), 1) {
+			n++
+		}
+
+		return n
+	case m.Summary != nil:
+		return len(m.Summary.Quantile) + 2
+	default:
+		return 1
+	}
+}
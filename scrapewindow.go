@@ -0,0 +1,41 @@
+package unifiexporter
+
+// siteScrapeWindow is the number of recent collections considered when
+// computing the scrape success ratio for a site.
+const siteScrapeWindow = 10
+
+// A scrapeWindow tracks whether each of the most recent collections for a
+// site succeeded or failed.
+type scrapeWindow struct {
+	results []bool
+	next    int
+}
+
+// add records the result of a single collection, evicting the oldest result
+// if the window is full.
+func (w *scrapeWindow) add(ok bool) {
+	if len(w.results) < siteScrapeWindow {
+		w.results = append(w.results, ok)
+		return
+	}
+
+	w.results[w.next] = ok
+	w.next = (w.next + 1) % siteScrapeWindow
+}
+
+// ratio returns the ratio of successful collections to all collections in
+// the window.
+func (w *scrapeWindow) ratio() float64 {
+	if len(w.results) == 0 {
+		return 0
+	}
+
+	var ok int
+	for _, r := range w.results {
+		if r {
+			ok++
+		}
+	}
+
+	return float64(ok) / float64(len(w.results))
+}
@@ -0,0 +1,127 @@
+package unifiexporter
+
+import (
+	"github.com/mdlayher/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A SiteCollector is a Prometheus collector for summary metrics regarding
+// Ubiquiti UniFi sites.  A SiteCollector reports the summary provided when
+// sites are listed, and makes no API calls of its own: when used by an
+// Exporter, the site list retrieved for each collection is reported, and
+// otherwise the site list it was created with is reported.
+type SiteCollector struct {
+	AccessPoints *prometheus.Desc
+	Stations     *prometheus.Desc
+	RoleInfo     *prometheus.Desc
+
+	sites []*unifi.Site
+	info  *controllerState
+}
+
+// Verify that the Exporter implements the collector interface.
+var _ collector = &SiteCollector{}
+
+// NewSiteCollector creates a new SiteCollector which collects metrics for
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewSiteCollector(sites []*unifi.Site, cfg *Config) *SiteCollector {
+	const (
+		subsystem = "site"
+	)
+
+	var (
+		labelsSite = []string{"site", "role"}
+	)
+
+	return &SiteCollector{
+		AccessPoints: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "access_points"),
+			"Number of access points in a site, as reported when listing sites",
+			labelsSite,
+			nil,
+		),
+
+		Stations: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "stations"),
+			"Number of stations in a site, as reported when listing sites",
+			labelsSite,
+			nil,
+		),
+
+		RoleInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "role_info"),
+			"Role of the exporter's UniFi Controller account in a site, such as admin or readonly",
+			labelsSite,
+			nil,
+		),
+
+		sites: sites,
+		info:  cfg.controllerState(),
+	}
+}
+
+// collect begins a metrics collection task for all metrics related to UniFi
+// sites.
+func (c *SiteCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
+	info := c.info.get()
+
+	for _, s := range c.sites {
+		// Prefer the summary retrieved for this collection, since the
+		// summary the collector was created with may be out of date
+		s = info.site(s)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.AccessPoints,
+			prometheus.GaugeValue,
+			float64(s.NumAPs),
+			s.Description,
+			s.Role,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.Stations,
+			prometheus.GaugeValue,
+			float64(s.NumStations),
+			s.Description,
+			s.Role,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.RoleInfo,
+			prometheus.GaugeValue,
+			1,
+			s.Description,
+			s.Role,
+		)
+	}
+
+	return nil, nil
+}
+
+// Describe sends the descriptors of each metric over to the provided channel.
+// The corresponding metric values are sent separately.
+func (c *SiteCollector) Describe(ch chan<- *prometheus.Desc) {
+	ds := []*prometheus.Desc{
+		c.AccessPoints,
+		c.Stations,
+		c.RoleInfo,
+	}
+
+	for _, d := range ds {
+		ch <- d
+	}
+}
+
+// Collect is the same as CollectError, but ignores any errors which occur.
+// Collect exists to satisfy the prometheus.Collector interface.
+func (c *SiteCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.CollectError(ch)
+}
+
+// CollectError sends the metric values for each metric pertaining to UniFi
+// sites over to the provided prometheus Metric channel, returning any errors
+// which occur.
+func (c *SiteCollector) CollectError(ch chan<- prometheus.Metric) error {
+	_, err := c.collect(ch)
+	return err
+}
@@ -0,0 +1,109 @@
+package unifiexporter
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mdlayher/unifi"
+)
+
+func TestSiteCollector(t *testing.T) {
+	var tests = []struct {
+		desc    string
+		sites   []*unifi.Site
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "one empty site",
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+				Role:        "admin",
+			}},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_access_points{role="admin",site="Default"} 0`),
+				regexp.MustCompile(`unifi_site_stations{role="admin",site="Default"} 0`),
+			},
+		},
+		{
+			desc: "two sites with differing roles",
+			sites: []*unifi.Site{
+				{
+					Name:        "default",
+					Description: "Default",
+					NumAPs:      3,
+					NumStations: 20,
+					Role:        "admin",
+				},
+				{
+					Name:        "abcdef",
+					Description: "Some Site",
+					NumAPs:      1,
+					NumStations: 4,
+					Role:        "readonly",
+				},
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_access_points{role="admin",site="Default"} 3`),
+				regexp.MustCompile(`unifi_site_stations{role="admin",site="Default"} 20`),
+				regexp.MustCompile(`unifi_site_access_points{role="readonly",site="Some Site"} 1`),
+				regexp.MustCompile(`unifi_site_stations{role="readonly",site="Some Site"} 4`),
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		out := testCollector(t, NewSiteCollector(tt.sites, nil))
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex.")
+			}
+		}
+	}
+}
+
+func TestSiteCollectorRoleInfo(t *testing.T) {
+	input := strings.TrimSpace(`
+[
+	{
+		"_id": "abc",
+		"desc": "Default",
+		"name": "default",
+		"role": "admin"
+	},
+	{
+		"_id": "def",
+		"desc": "Some Site",
+		"name": "abcdef",
+		"role": "readonly"
+	}
+]
+`)
+
+	var sites []*unifi.Site
+	if err := json.Unmarshal([]byte(input), &sites); err != nil {
+		t.Fatalf("failed to unmarshal sites: %v", err)
+	}
+
+	out := testCollector(t, NewSiteCollector(sites, nil))
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_site_role_info{role="admin",site="Default"} 1`),
+		regexp.MustCompile(`unifi_site_role_info{role="readonly",site="Some Site"} 1`),
+	}
+
+	for i, m := range matches {
+		t.Logf("[%02d] match: %s", i, m.String())
+
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex:\n%s", string(out))
+		}
+	}
+}
@@ -1,7 +1,8 @@
 package unifiexporter
 
 import (
-	"log"
+	"context"
+	"time"
 
 	"github.com/mdlayher/unifi"
 	"github.com/prometheus/client_golang/prometheus"
@@ -10,48 +11,107 @@ import (
 // A StationCollector is a Prometheus collector for metrics regarding Ubiquiti
 // UniFi stations (clients).
 type StationCollector struct {
-	Stations *prometheus.Desc
+	Stations     *prometheus.Desc
+	KnownClients *prometheus.Desc
+	IdleStations *prometheus.Desc
 
 	ReceivedBytesTotal    *prometheus.Desc
 	TransmittedBytesTotal *prometheus.Desc
 
+	SiteReceivedBytes    *prometheus.Desc
+	SiteTransmittedBytes *prometheus.Desc
+
+	SiteBandSteeringRatio *prometheus.Desc
+
+	SiteStationCountChange *prometheus.Desc
+
+	PHYUpgradeCandidates *prometheus.Desc
+
 	ReceivedPacketsTotal    *prometheus.Desc
 	TransmittedPacketsTotal *prometheus.Desc
 
-	RSSIDBM  *prometheus.Desc
-	NoiseDBM *prometheus.Desc
+	ReceiveRateBPS  *prometheus.Desc
+	TransmitRateBPS *prometheus.Desc
+
+	ThroughputBytesPerSecond *prometheus.Desc
+
+	RSSIDBM          *prometheus.Desc
+	NoiseDBM         *prometheus.Desc
+	TransmitPowerDBM *prometheus.Desc
+
+	TransmitPowerHeadroomDBM *prometheus.Desc
+
+	CurrentAPSeconds   *prometheus.Desc
+	AssociationTime    *prometheus.Desc
+	Channel            *prometheus.Desc
+	UptimeSecondsTotal *prometheus.Desc
+	IdleSeconds        *prometheus.Desc
+	RoamCount          *prometheus.Desc
+
+	Guest     *prometheus.Desc
+	GuestByAP *prometheus.Desc
+
+	ExperienceBucket *prometheus.Desc
 
 	c     *unifi.Client
 	sites []*unifi.Site
+
+	timestamps  bool
+	counters    *counterTracker
+	deltas      *deltaTracker
+	thresholds  ExperienceThresholds
+	label       string
+	oui         bool
+	controller  string
+	idle        time.Duration
+	concurrency int
+	endpoints   *endpointTracker
+	unique      *stationSet
+	cache       *responseCache
+	timeout     time.Duration
+	logger      *errorLogger
 }
 
 // Verify that the Exporter implements the prometheus.Collector interface.
 var _ collector = &StationCollector{}
 
 // NewStationCollector creates a new StationCollector which collects metrics for
-// a specified site.
-func NewStationCollector(c *unifi.Client, sites []*unifi.Site) *StationCollector {
+// a specified site.  If cfg is nil, a default configuration is used.
+func NewStationCollector(c *unifi.Client, sites []*unifi.Site, cfg *Config) *StationCollector {
 	const (
 		subsystem = "stations"
 	)
 
 	var (
 		labelsSiteOnly = []string{"site"}
-		labelsStation  = []string{
-			"site",
-			"id",
-			"ap_mac",
-			"station_mac",
-			"hostname",
-			"connection",
-		}
+		labelsBucket   = []string{"site", "bucket"}
+		labelsStation  = stationLabels(cfg.orDefault().StationLabel)
+		labelsTraffic  = labelsStation
 	)
 
+	if cfg.orDefault().StationOUILabel {
+		labelsTraffic = append(stationLabels(cfg.orDefault().StationLabel), "oui")
+	}
+
 	return &StationCollector{
 		Stations: prometheus.NewDesc(
 			// Subsystem is used as name so we get "unifi_stations"
 			prometheus.BuildFQName(namespace, "", subsystem),
 			"Total number of stations (clients)",
+			siteLabels(cfg.orDefault().Controller),
+			nil,
+		),
+
+		KnownClients: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "known_clients", "total"),
+			"Total number of clients ever seen by the controller, whether or not they are currently connected",
+			labelsSiteOnly,
+			nil,
+		),
+
+		IdleStations: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "idle_total"),
+			"Number of associated stations which have not sent or received traffic within the idle threshold",
 			labelsSiteOnly,
 			nil,
 		),
@@ -59,27 +119,83 @@ func NewStationCollector(c *unifi.Client, sites []*unifi.Site) *StationCollector
 		ReceivedBytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "received_bytes_total"),
 			"Number of bytes received by the AP for stations (client upload)",
-			labelsStation,
+			labelsTraffic,
 			nil,
 		),
 
 		TransmittedBytesTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "transmitted_bytes_total"),
 			"Number of bytes transmitted by the AP to stations (client download)",
-			labelsStation,
+			labelsTraffic,
+			nil,
+		),
+
+		SiteReceivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "station_received_bytes"),
+			"Number of bytes received by the AP for the stations currently connected to a site (client upload)",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SiteTransmittedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "station_transmitted_bytes"),
+			"Number of bytes transmitted by the AP to the stations currently connected to a site (client download)",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SiteBandSteeringRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "band_steering_ratio"),
+			"Ratio of wireless stations connected on the 5GHz band to all wireless stations in a site",
+			labelsSiteOnly,
+			nil,
+		),
+
+		SiteStationCountChange: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "station_count_change"),
+			"Change in the number of stations connected to a site since the previous scrape",
+			labelsSiteOnly,
+			nil,
+		),
+
+		PHYUpgradeCandidates: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "phy_upgrade_candidates"),
+			"Number of wireless stations using a slower PHY mode than their AP's radio supports",
+			labelsSiteOnly,
 			nil,
 		),
 
 		ReceivedPacketsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "received_packets_total"),
 			"Number of packets received by the AP for stations (client upload)",
-			labelsStation,
+			labelsTraffic,
 			nil,
 		),
 
 		TransmittedPacketsTotal: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "transmitted_packets_total"),
 			"Number of packets transmitted by the AP for stations (client download)",
+			labelsTraffic,
+			nil,
+		),
+
+		ReceiveRateBPS: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "receive_rate_bps"),
+			"Current receive rate of stations in bits per second",
+			labelsStation,
+			nil,
+		),
+
+		TransmitRateBPS: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "transmit_rate_bps"),
+			"Current transmit rate of stations in bits per second",
+			labelsStation,
+			nil,
+		),
+
+		ThroughputBytesPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "throughput_bytes_per_second"),
+			"Current combined receive and transmit throughput of stations in bytes per second",
 			labelsStation,
 			nil,
 		),
@@ -98,31 +214,188 @@ func NewStationCollector(c *unifi.Client, sites []*unifi.Site) *StationCollector
 			nil,
 		),
 
+		TransmitPowerDBM: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "transmit_power_dbm"),
+			"Current transmit power of stations",
+			labelsStation,
+			nil,
+		),
+
+		TransmitPowerHeadroomDBM: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "tx_power_headroom_dbm"),
+			"Difference between the maximum and current transmit power of stations",
+			labelsStation,
+			nil,
+		),
+
+		CurrentAPSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "current_ap_seconds"),
+			"Number of seconds stations have been connected to their current AP",
+			labelsStation,
+			nil,
+		),
+
+		AssociationTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "association_timestamp_seconds"),
+			"UNIX timestamp of when wireless stations associated with their current AP",
+			labelsStation,
+			nil,
+		),
+
+		Channel: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "channel"),
+			"Radio channel wireless stations are associated on",
+			labelsStation,
+			nil,
+		),
+
+		UptimeSecondsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "uptime_seconds_total"),
+			"Number of seconds stations have been connected",
+			labelsStation,
+			nil,
+		),
+
+		IdleSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "idle_seconds"),
+			"Number of seconds since wireless stations last sent or received traffic",
+			labelsStation,
+			nil,
+		),
+
+		RoamCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "roam_count"),
+			"Number of times stations have roamed between APs",
+			labelsStation,
+			nil,
+		),
+
+		Guest: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "guest"),
+			"Whether stations are classified as guests by the UniFi Controller (1) or not (0)",
+			labelsStation,
+			nil,
+		),
+
+		GuestByAP: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "guest_by_ap"),
+			"Whether stations are classified as guests by their AP (1) or not (0)",
+			labelsStation,
+			nil,
+		),
+
+		ExperienceBucket: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "experience_bucket"),
+			"Number of wireless stations in each experience bucket, based on satisfaction or signal strength",
+			labelsBucket,
+			nil,
+		),
+
 		c:     c,
 		sites: sites,
+
+		timestamps:  cfg.orDefault().Timestamps,
+		counters:    cfg.counterTracker(),
+		deltas:      cfg.deltaTracker(),
+		thresholds:  cfg.experienceThresholds(),
+		label:       cfg.orDefault().StationLabel,
+		oui:         cfg.orDefault().StationOUILabel,
+		controller:  cfg.orDefault().Controller,
+		idle:        cfg.idleThreshold(),
+		concurrency: cfg.siteConcurrency(),
+		endpoints:   cfg.endpointTracker(),
+		unique:      cfg.stationSet(),
+		cache:       cfg.responseCache(),
+		timeout:     cfg.orDefault().ScrapeTimeout,
+		logger:      cfg.orDefault().logger,
 	}
 }
 
 // collect begins a metrics collection task for all metrics related to UniFi
 // stations.
 func (c *StationCollector) collect(ch chan<- prometheus.Metric) (*prometheus.Desc, error) {
-	for _, s := range c.sites {
-		stations, err := c.c.Stations(s.Name)
-		if err != nil {
-			return c.Stations, err
-		}
+	ctx, cancel := scrapeContext(c.timeout)
+	defer cancel()
+
+	return forEachSite(c.sites, c.concurrency, func(s *unifi.Site) (*prometheus.Desc, error) {
+		return c.collectSite(ctx, ch, s)
+	})
+}
+
+// collectSite collects metrics for the UniFi stations in site s, using ctx for
+// requests.
+func (c *StationCollector) collectSite(ctx context.Context, ch chan<- prometheus.Metric, s *unifi.Site) (*prometheus.Desc, error) {
+	stations, err := c.cache.stations(ctx, c.c, s.Name)
+	if err != nil {
+		return c.Stations, &siteError{site: s, err: err}
+	}
+	c.endpoints.success(endpointStations)
+
+	for _, st := range stations {
+		c.unique.add(st.MAC)
+	}
+
+	// Devices are only used to resolve the name of the AP each station
+	// is connected to, so if they cannot be retrieved, AP names are left
+	// empty
+	devices, err := c.cache.devices(ctx, c.c, s.Name)
+	if err != nil {
+		c.logger.Printf("[ERROR] failed retrieving UniFi Controller devices for site %q: %v", s.Name, err)
+	} else {
+		c.endpoints.success(endpointDevices)
+	}
+	apNames := deviceNames(devices)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.Stations,
+		prometheus.GaugeValue,
+		float64(len(stations)),
+		siteLabelValues(c.controller, s)...,
+	)
+
+	// Known clients are only used for a single metric, so a failure to
+	// retrieve them should not prevent collecting the others
+	known, err := c.cache.knownClients(ctx, c.c, s.Name)
+	if err != nil {
+		c.logger.Printf("[ERROR] failed retrieving UniFi Controller known clients for site %q: %v", s.Name, err)
+	} else {
+		c.endpoints.success(endpointKnownClients)
 
 		ch <- prometheus.MustNewConstMetric(
-			c.Stations,
+			c.KnownClients,
 			prometheus.GaugeValue,
-			float64(len(stations)),
+			float64(len(known)),
 			s.Description,
 		)
+	}
 
-		c.collectStationBytes(ch, s.Description, stations)
-		c.collectStationSignal(ch, s.Description, stations)
+	var idle int
+	for _, st := range stations {
+		if st.IdleTime >= c.idle {
+			idle++
+		}
 	}
 
+	ch <- prometheus.MustNewConstMetric(
+		c.IdleStations,
+		prometheus.GaugeValue,
+		float64(idle),
+		s.Description,
+	)
+
+	c.collectStationBytes(ch, s.Description, apNames, stations)
+	c.collectSiteStationBytes(ch, s.Description, stations)
+	c.collectSiteBandSteering(ch, s.Description, stations)
+	c.collectSiteStationCountChange(ch, s.Description, stations)
+	c.collectPHYUpgradeCandidates(ch, s.Description, devices, stations)
+	c.collectStationRates(ch, s.Description, apNames, stations)
+	c.collectStationSignal(ch, s.Description, apNames, stations)
+	c.collectStationExperience(ch, s.Description, stations)
+	c.collectStationCurrentAP(ch, s.Description, apNames, stations)
+	c.collectStationUptime(ch, s.Description, apNames, stations)
+	c.collectStationRoaming(ch, s.Description, apNames, stations)
+	c.collectStationGuests(ch, s.Description, apNames, stations)
+
 	return nil, nil
 }
 
@@ -135,6 +408,86 @@ func hostName(s *unifi.Station) string {
 	return s.Hostname
 }
 
+// stationLabels returns the label names for per-station metrics, using label
+// as the sole station identifier if it is not empty.
+func stationLabels(label string) []string {
+	if label == "" {
+		return []string{
+			"site",
+			"id",
+			"ap_mac",
+			"ap_name",
+			"station_mac",
+			"hostname",
+			"connection",
+		}
+	}
+
+	return []string{
+		"site",
+		label,
+		"ap_mac",
+		"ap_name",
+		"connection",
+	}
+}
+
+// labels returns the label values for station s, in the order produced by
+// stationLabels.
+func (c *StationCollector) labels(siteLabel string, apNames map[string]string, s *unifi.Station) []string {
+	if c.label == "" {
+		return []string{
+			siteLabel,
+			s.ID,
+			s.APMAC.String(),
+			apNames[s.APMAC.String()],
+			s.MAC.String(),
+			hostName(s),
+			connType(s),
+		}
+	}
+
+	var id string
+	switch c.label {
+	case StationLabelHostname:
+		id = hostName(s)
+	case StationLabelID:
+		id = s.ID
+	}
+
+	// Fall back to the MAC address for stations which do not report the
+	// chosen identifier
+	if id == "" {
+		id = s.MAC.String()
+	}
+
+	return []string{
+		siteLabel,
+		id,
+		s.APMAC.String(),
+		apNames[s.APMAC.String()],
+		connType(s),
+	}
+}
+
+// deviceNames returns a map of device MAC addresses to device names, used to
+// resolve the name of the AP a station is connected to.
+func deviceNames(devices []*unifi.Device) map[string]string {
+	names := make(map[string]string, len(devices))
+	for _, d := range devices {
+		// Some devices report no NICs, and are identified only by their
+		// own MAC address
+		if len(d.MAC) > 0 {
+			names[d.MAC.String()] = d.Name
+		}
+		for _, n := range d.NICs {
+			names[n.MAC.String()] = d.Name
+		}
+	}
+
+	return names
+}
+
 // connType returns a string indicating if a station is connected using a wired
 // or wireless connection.
 func connType(s *unifi.Station) string {
@@ -146,59 +499,205 @@ func connType(s *unifi.Station) string {
 }
 
 // collectStationBytes collects receive and transmit byte counts for UniFi stations.
-func (c *StationCollector) collectStationBytes(ch chan<- prometheus.Metric, siteLabel string, stations []*unifi.Station) {
+func (c *StationCollector) collectStationBytes(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
 	for _, s := range stations {
-		labels := []string{
-			siteLabel,
-			s.ID,
-			s.APMAC.String(),
-			s.MAC.String(),
-			hostName(s),
-			connType(s),
+		labels := c.labels(siteLabel, apNames, s)
+		if c.oui {
+			labels = append(labels, s.OUI)
 		}
 
+		ch <- c.lastSeen(c.counter(c.ReceivedBytesTotal, float64(s.Stats.ReceiveBytes), labels...), s)
+		ch <- c.lastSeen(c.counter(c.TransmittedBytesTotal, float64(s.Stats.TransmitBytes), labels...), s)
+
+		ch <- c.lastSeen(c.counter(c.ReceivedPacketsTotal, float64(s.Stats.ReceivePackets), labels...), s)
+		ch <- c.lastSeen(c.counter(c.TransmittedPacketsTotal, float64(s.Stats.TransmitPackets), labels...), s)
+	}
+}
+
+// collectSiteStationBytes collects the sum of receive and transmit byte counts
+// for the UniFi stations currently connected to a site.  The sums decrease
+// when stations disconnect, so they are gauges rather than counters.
+func (c *StationCollector) collectSiteStationBytes(ch chan<- prometheus.Metric, siteLabel string, stations []*unifi.Station) {
+	var rx, tx int64
+	for _, s := range stations {
+		rx += s.Stats.ReceiveBytes
+		tx += s.Stats.TransmitBytes
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.SiteReceivedBytes,
+		prometheus.GaugeValue,
+		float64(rx),
+		siteLabel,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.SiteTransmittedBytes,
+		prometheus.GaugeValue,
+		float64(tx),
+		siteLabel,
+	)
+}
+
+// collectSiteStationCountChange collects the change in the number of UniFi
+// stations in a site since the previous collection, so that sudden mass
+// disconnects are visible as a large negative value.
+func (c *StationCollector) collectSiteStationCountChange(ch chan<- prometheus.Metric, siteLabel string, stations []*unifi.Station) {
+	ch <- prometheus.MustNewConstMetric(
+		c.SiteStationCountChange,
+		prometheus.GaugeValue,
+		c.deltas.delta(c.SiteStationCountChange, float64(len(stations)), siteLabel),
+		siteLabel,
+	)
+}
+
+// collectSiteBandSteering collects the ratio of wireless UniFi stations in a
+// site which are connected on the 5GHz band.
+func (c *StationCollector) collectSiteBandSteering(ch chan<- prometheus.Metric, siteLabel string, stations []*unifi.Station) {
+	var wireless, fiveGHz int
+	for _, s := range stations {
+		if s.IsWired {
+			continue
+		}
+
+		wireless++
+		if s.Radio == "5GHz" {
+			fiveGHz++
+		}
+	}
+
+	// Avoid dividing by zero for sites with no wireless stations
+	if wireless == 0 {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.SiteBandSteeringRatio,
+		prometheus.GaugeValue,
+		float64(fiveGHz)/float64(wireless),
+		siteLabel,
+	)
+}
+
+// phyModes ranks the PHY modes reported for wireless stations, from slowest
+// to fastest.
+var phyModes = map[string]int{
+	"a":  1,
+	"b":  1,
+	"g":  1,
+	"na": 2,
+	"ng": 2,
+	"ac": 3,
+	"ax": 4,
+}
+
+// radioPHYMode returns the rank of the fastest PHY mode supported by radio r.
+func radioPHYMode(r *unifi.Radio) int {
+	switch {
+	case r.Is11AX:
+		return phyModes["ax"]
+	case r.Is11AC:
+		return phyModes["ac"]
+	default:
+		return phyModes["na"]
+	}
+}
+
+// collectPHYUpgradeCandidates collects the number of wireless stations which
+// use a slower PHY mode than the radio of the AP they are connected to
+// supports on the same band.
+func (c *StationCollector) collectPHYUpgradeCandidates(ch chan<- prometheus.Metric, siteLabel string, devices []*unifi.Device, stations []*unifi.Station) {
+	// Map each radio band of each AP to the fastest PHY mode it supports
+	radios := make(map[string]int)
+	for _, d := range devices {
+		for _, n := range d.NICs {
+			for _, r := range d.Radios {
+				radios[n.MAC.String()+r.Radio] = radioPHYMode(r)
+			}
+		}
+	}
+
+	var candidates int
+	for _, s := range stations {
+		if s.IsWired {
+			continue
+		}
+
+		mode, ok := phyModes[s.RadioProto]
+		if !ok {
+			continue
+		}
+
+		if max, ok := radios[s.APMAC.String()+s.Radio]; ok && mode < max {
+			candidates++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.PHYUpgradeCandidates,
+		prometheus.GaugeValue,
+		float64(candidates),
+		siteLabel,
+	)
+}
+
+// lastSeen applies the time the UniFi Controller last saw s to m, if c is
+// configured to expose timestamps.
+func (c *StationCollector) lastSeen(m prometheus.Metric, s *unifi.Station) prometheus.Metric {
+	if !c.timestamps {
+		return m
+	}
+
+	return withTimestamp(m, s.LastSeen)
+}
+
+// counter creates a counter metric for desc with value v, which is adjusted
+// for counter resets if c is configured to expose monotonic counters.
+func (c *StationCollector) counter(desc *prometheus.Desc, v float64, labels ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(
+		desc,
+		prometheus.CounterValue,
+		c.counters.value(desc, v, labels...),
+		labels...,
+	)
+}
+
+// collectStationRates collects receive and transmit rates for UniFi stations.
+func (c *StationCollector) collectStationRates(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
+	for _, s := range stations {
+		labels := c.labels(siteLabel, apNames, s)
+
+		// Rates are reported in kilobits per second
 		ch <- prometheus.MustNewConstMetric(
-			c.ReceivedBytesTotal,
-			prometheus.CounterValue,
-			float64(s.Stats.ReceiveBytes),
-			labels...,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			c.TransmittedBytesTotal,
-			prometheus.CounterValue,
-			float64(s.Stats.TransmitBytes),
+			c.ReceiveRateBPS,
+			prometheus.GaugeValue,
+			float64(s.Stats.ReceiveRate)*1000,
 			labels...,
 		)
 
 		ch <- prometheus.MustNewConstMetric(
-			c.ReceivedPacketsTotal,
-			prometheus.CounterValue,
-			float64(s.Stats.ReceivePackets),
+			c.TransmitRateBPS,
+			prometheus.GaugeValue,
+			float64(s.Stats.TransmitRate)*1000,
 			labels...,
 		)
+
 		ch <- prometheus.MustNewConstMetric(
-			c.TransmittedPacketsTotal,
-			prometheus.CounterValue,
-			float64(s.Stats.TransmitPackets),
+			c.ThroughputBytesPerSecond,
+			prometheus.GaugeValue,
+			float64(s.Stats.BytesPerSecond),
 			labels...,
 		)
 	}
 }
 
-// collectStationSignal collects wireless signal strength for UniFi stations.
-func (c *StationCollector) collectStationSignal(ch chan<- prometheus.Metric, siteLabel string, stations []*unifi.Station) {
+// collectStationSignal collects wireless signal strength, noise, and transmit
+// power for wireless UniFi stations.
+func (c *StationCollector) collectStationSignal(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
 	for _, s := range stations {
 		if s.IsWired {
 			continue
 		}
-		labels := []string{
-			siteLabel,
-			s.ID,
-			s.APMAC.String(),
-			s.MAC.String(),
-			hostName(s),
-			connType(s),
-		}
+		labels := c.labels(siteLabel, apNames, s)
 
 		ch <- prometheus.MustNewConstMetric(
 			c.RSSIDBM,
@@ -213,6 +712,164 @@ func (c *StationCollector) collectStationSignal(ch chan<- prometheus.Metric, sit
 			float64(s.Noise),
 			labels...,
 		)
+
+		// Transmit power is not reported by all stations
+		if s.Stats.TransmitPower < 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.TransmitPowerDBM,
+			prometheus.GaugeValue,
+			float64(s.Stats.TransmitPower),
+			labels...,
+		)
+
+		if s.Stats.TransmitPowerMax < 0 {
+			continue
+		}
+
+		// A station with no headroom is already transmitting at its
+		// maximum power, which may indicate poor coverage
+		ch <- prometheus.MustNewConstMetric(
+			c.TransmitPowerHeadroomDBM,
+			prometheus.GaugeValue,
+			float64(s.Stats.TransmitPowerMax-s.Stats.TransmitPower),
+			labels...,
+		)
+	}
+}
+
+// collectStationCurrentAP collects the time wireless UniFi stations have been
+// connected to their current AP, and the channel they are associated on.
+func (c *StationCollector) collectStationCurrentAP(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
+	for _, s := range stations {
+		if s.IsWired {
+			continue
+		}
+
+		labels := c.labels(siteLabel, apNames, s)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.CurrentAPSeconds,
+			prometheus.GaugeValue,
+			float64(s.UptimeByAP/time.Second),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.Channel,
+			prometheus.GaugeValue,
+			float64(s.Channel),
+			labels...,
+		)
+
+		// Station has not reported an association time
+		if s.AssociationTime.Unix() <= 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.AssociationTime,
+			prometheus.GaugeValue,
+			float64(s.AssociationTime.Unix()),
+			labels...,
+		)
+	}
+}
+
+// collectStationUptime collects the time UniFi stations have been connected.
+func (c *StationCollector) collectStationUptime(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
+	for _, s := range stations {
+		ch <- prometheus.MustNewConstMetric(
+			c.UptimeSecondsTotal,
+			prometheus.CounterValue,
+			float64(s.Uptime/time.Second),
+			c.labels(siteLabel, apNames, s)...,
+		)
+	}
+}
+
+// collectStationRoaming collects the roam count of UniFi stations, and the
+// idle time of wireless UniFi stations.
+func (c *StationCollector) collectStationRoaming(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
+	for _, s := range stations {
+		labels := c.labels(siteLabel, apNames, s)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.RoamCount,
+			prometheus.GaugeValue,
+			float64(s.RoamCount),
+			labels...,
+		)
+
+		if s.IsWired {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.IdleSeconds,
+			prometheus.GaugeValue,
+			float64(s.IdleTime/time.Second),
+			labels...,
+		)
+	}
+}
+
+// collectStationGuests collects whether UniFi stations are classified as
+// guests by the UniFi Controller and by their AP, which may disagree.
+func (c *StationCollector) collectStationGuests(ch chan<- prometheus.Metric, siteLabel string, apNames map[string]string, stations []*unifi.Station) {
+	for _, s := range stations {
+		labels := c.labels(siteLabel, apNames, s)
+
+		var guest, guestByAP float64
+		if s.IsGuest {
+			guest = 1
+		}
+		if s.IsGuestByAP {
+			guestByAP = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.Guest,
+			prometheus.GaugeValue,
+			guest,
+			labels...,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.GuestByAP,
+			prometheus.GaugeValue,
+			guestByAP,
+			labels...,
+		)
+	}
+}
+
+// collectStationExperience collects the number of wireless stations in each
+// experience bucket.
+func (c *StationCollector) collectStationExperience(ch chan<- prometheus.Metric, siteLabel string, stations []*unifi.Station) {
+	buckets := map[string]int{
+		"poor": 0,
+		"fair": 0,
+		"good": 0,
+	}
+
+	for _, s := range stations {
+		if s.IsWired {
+			continue
+		}
+
+		buckets[c.thresholds.bucket(s)]++
+	}
+
+	for b, n := range buckets {
+		ch <- prometheus.MustNewConstMetric(
+			c.ExperienceBucket,
+			prometheus.GaugeValue,
+			float64(n),
+			siteLabel,
+			b,
+		)
 	}
 }
 
@@ -221,15 +878,46 @@ func (c *StationCollector) collectStationSignal(ch chan<- prometheus.Metric, sit
 func (c *StationCollector) Describe(ch chan<- *prometheus.Desc) {
 	ds := []*prometheus.Desc{
 		c.Stations,
+		c.KnownClients,
+		c.IdleStations,
 
 		c.ReceivedBytesTotal,
 		c.TransmittedBytesTotal,
 
+		c.SiteReceivedBytes,
+		c.SiteTransmittedBytes,
+
+		c.SiteBandSteeringRatio,
+
+		c.SiteStationCountChange,
+
+		c.PHYUpgradeCandidates,
+
 		c.ReceivedPacketsTotal,
 		c.TransmittedPacketsTotal,
 
+		c.ReceiveRateBPS,
+		c.TransmitRateBPS,
+
+		c.ThroughputBytesPerSecond,
+
 		c.RSSIDBM,
 		c.NoiseDBM,
+		c.TransmitPowerDBM,
+
+		c.TransmitPowerHeadroomDBM,
+
+		c.CurrentAPSeconds,
+		c.AssociationTime,
+		c.Channel,
+		c.UptimeSecondsTotal,
+		c.IdleSeconds,
+		c.RoamCount,
+
+		c.Guest,
+		c.GuestByAP,
+
+		c.ExperienceBucket,
 	}
 
 	for _, d := range ds {
@@ -248,7 +936,7 @@ func (c *StationCollector) Collect(ch chan<- prometheus.Metric) {
 // errors which occur.
 func (c *StationCollector) CollectError(ch chan<- prometheus.Metric) error {
 	if desc, err := c.collect(ch); err != nil {
-		log.Printf("[ERROR] failed collecting station metric %v: %v", desc, err)
+		c.logger.Printf("[ERROR] failed collecting station metric %v: %v", desc, err)
 		ch <- prometheus.NewInvalidMetric(desc, err)
 		return err
 	}
@@ -1,9 +1,12 @@
 package unifiexporter
 
 import (
+	"bytes"
+	"fmt"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mdlayher/unifi"
 )
@@ -12,6 +15,8 @@ func TestStationCollector(t *testing.T) {
 	var tests = []struct {
 		desc    string
 		input   string
+		devices string
+		cfg     *Config
 		sites   []*unifi.Site
 		matches []*regexp.Regexp
 	}{
@@ -38,14 +43,14 @@ func TestStationCollector(t *testing.T) {
 			matches: []*regexp.Regexp{
 				regexp.MustCompile(`unifi_stations{site="Default"} 1`),
 
-				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
-				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
 
-				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
-				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
 
-				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -110`),
-				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
+				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -110`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
 			},
 			sites: []*unifi.Site{{
 				Name:        "default",
@@ -73,11 +78,11 @@ func TestStationCollector(t *testing.T) {
 			matches: []*regexp.Regexp{
 				regexp.MustCompile(`unifi_stations{site="Default"} 1`),
 
-				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
-				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="",ap_name="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="",ap_name="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
 
-				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
-				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="",ap_name="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="",ap_name="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
 			},
 			sites: []*unifi.Site{{
 				Name:        "default",
@@ -119,23 +124,23 @@ func TestStationCollector(t *testing.T) {
 			matches: []*regexp.Regexp{
 				regexp.MustCompile(`unifi_stations{site="Default"} 2`),
 
-				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
-				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
 
-				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
-				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
 
-				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -110`),
-				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
+				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -110`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
 
-				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 100`),
-				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 200`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 100`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 200`),
 
-				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 10`),
-				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 20`),
 
-				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} -110`),
-				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 50`),
+				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} -110`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 50`),
 			},
 			sites: []*unifi.Site{{
 				Name:        "default",
@@ -165,25 +170,25 @@ func TestStationCollector(t *testing.T) {
 			matches: []*regexp.Regexp{
 				regexp.MustCompile(`unifi_stations{site="Default"} 1`),
 
-				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
-				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
 
-				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
-				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
 
-				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -110`),
-				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
+				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -110`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
 
 				regexp.MustCompile(`unifi_stations{site="Some Site"} 1`),
 
-				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 10`),
-				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 20`),
 
-				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 1`),
-				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 2`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 2`),
 
-				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} -110`),
-				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 40`),
+				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} -110`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Some Site",station_mac="de:ad:be:ef:de:ad"} 40`),
 			},
 			sites: []*unifi.Site{
 				{
@@ -196,12 +201,966 @@ func TestStationCollector(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "one station connected to a known AP, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"noise": -110,
+			"rssi": 40,
+			"rx_bytes": 10,
+			"rx_packets": 1,
+			"tx_bytes": 20,
+			"tx_packets": 2
+		}
+	]
+}
+`),
+			devices: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Lobby AP",
+			"ethernet_table": [{
+				"mac": "a0:a0:a0:a0:a0:a0"
+			}]
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="Lobby AP",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="Lobby AP",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one station connected to a known AP without NICs, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"rx_bytes": 10
+		}
+	]
+}
+`),
+			devices: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"mac": "a0:a0:a0:a0:a0:a0",
+			"name": "Lobby AP"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="Lobby AP",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "devices unavailable, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"rx_bytes": 10
+		}
+	]
+}
+`),
+			devices: `{`,
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations{site="Default"} 1`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "stations using slower PHY modes than their AP, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"radio": "na",
+			"radio_proto": "ac"
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"radio": "na",
+			"radio_proto": "ax"
+		},
+		{
+			"_id": "fedcba",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "00:11:22:33:44:55",
+			"radio": "ng",
+			"radio_proto": "ng"
+		},
+		{
+			"_id": "654321",
+			"ap_mac": "b0:b0:b0:b0:b0:b0",
+			"mac": "00:11:22:33:44:66",
+			"radio": "na",
+			"radio_proto": "na"
+		},
+		{
+			"_id": "aaaaaa",
+			"is_wired": true,
+			"mac": "00:11:22:33:44:77"
+		}
+	]
+}
+`),
+			devices: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"inform_ip": "192.168.1.1",
+			"name": "Lobby AP",
+			"ethernet_table": [{
+				"mac": "a0:a0:a0:a0:a0:a0"
+			}],
+			"radio_table": [
+				{
+					"name": "wifi0",
+					"radio": "ng"
+				},
+				{
+					"name": "wifi1",
+					"radio": "na",
+					"is_11ac": true,
+					"is_11ax": true
+				}
+			]
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_phy_upgrade_candidates{site="Default"} 1\n`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one station with timestamps, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"last_seen": 1500000000,
+			"rssi": 40,
+			"rx_bytes": 10,
+			"rx_packets": 1,
+			"tx_bytes": 20,
+			"tx_packets": 2
+		}
+	]
+}
+`),
+			cfg: &Config{
+				Timestamps: true,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10 1500000000000`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20 1500000000000`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1 1500000000000`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 2 1500000000000`),
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 40\n`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "experience buckets, default thresholds",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "a",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:01",
+			"rssi": 40,
+			"satisfaction": 95
+		},
+		{
+			"_id": "b",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:02",
+			"rssi": 40,
+			"satisfaction": 60
+		},
+		{
+			"_id": "c",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:03",
+			"rssi": 40,
+			"satisfaction": 10
+		},
+		{
+			"_id": "d",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:04",
+			"rssi": 20
+		},
+		{
+			"_id": "e",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:05",
+			"rssi": 5
+		},
+		{
+			"_id": "f",
+			"mac": "de:ad:be:ef:de:06",
+			"is_wired": true
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_experience_bucket{bucket="good",site="Default"} 1`),
+				regexp.MustCompile(`unifi_stations_experience_bucket{bucket="fair",site="Default"} 2`),
+				regexp.MustCompile(`unifi_stations_experience_bucket{bucket="poor",site="Default"} 2`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "experience buckets, custom thresholds",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "a",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:01",
+			"rssi": 40,
+			"satisfaction": 95
+		},
+		{
+			"_id": "b",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:02",
+			"rssi": 40,
+			"satisfaction": 60
+		},
+		{
+			"_id": "c",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:03",
+			"rssi": 40,
+			"satisfaction": 10
+		},
+		{
+			"_id": "d",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:04",
+			"rssi": 20
+		},
+		{
+			"_id": "e",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:05",
+			"rssi": 5
+		},
+		{
+			"_id": "f",
+			"mac": "de:ad:be:ef:de:06",
+			"is_wired": true
+		}
+	]
+}
+`),
+			cfg: &Config{
+				ExperienceThresholds: &ExperienceThresholds{
+					SatisfactionFair: 5,
+					SatisfactionGood: 50,
+					RSSIFair:         1,
+					RSSIGood:         10,
+				},
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_experience_bucket{bucket="good",site="Default"} 3`),
+				regexp.MustCompile(`unifi_stations_experience_bucket{bucket="fair",site="Default"} 2`),
+				regexp.MustCompile(`unifi_stations_experience_bucket{bucket="poor",site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "station label MAC",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"rx_bytes": 10
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"rx_bytes": 20
+		}
+	]
+}
+`),
+			cfg: &Config{
+				StationLabel: StationLabelMAC,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 20`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "station label hostname",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"rx_bytes": 10
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"rx_bytes": 20
+		}
+	]
+}
+`),
+			cfg: &Config{
+				StationLabel: StationLabelHostname,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",site="Default"} 10`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="ab:ad:1d:ea:ab:ad",site="Default"} 20`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "station label ID",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"rx_bytes": 10
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"rx_bytes": 20
+		}
+	]
+}
+`),
+			cfg: &Config{
+				StationLabel: StationLabelID,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",id="abcdef",site="Default"} 10`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",id="123456",site="Default"} 20`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "station OUI label disabled",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"oui": "Apple",
+			"rx_bytes": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "station OUI label enabled",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"oui": "Apple",
+			"rx_bytes": 10,
+			"tx_bytes": 20,
+			"rx_packets": 1,
+			"tx_packets": 2,
+			"rx_rate": 1000
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar",
+			"rx_bytes": 30
+		}
+	]
+}
+`),
+			cfg: &Config{
+				StationOUILabel: true,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",oui="Apple",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+				regexp.MustCompile(`unifi_stations_transmitted_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",oui="Apple",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
+				regexp.MustCompile(`unifi_stations_received_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",oui="Apple",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_transmitted_packets_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",oui="Apple",site="Default",station_mac="de:ad:be:ef:de:ad"} 2`),
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",oui="",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 30`),
+
+				// Other per-station metrics are not labeled with the OUI
+				regexp.MustCompile(`unifi_stations_receive_rate_bps{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1e\+06`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "station OUI label enabled with station label MAC",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"oui": "Apple",
+			"rx_bytes": 10
+		}
+	]
+}
+`),
+			cfg: &Config{
+				StationLabel:    StationLabelMAC,
+				StationOUILabel: true,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_received_bytes_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",oui="Apple",site="Default",station_mac="de:ad:be:ef:de:ad"} 10`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one station connected to its current AP, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"uptime": 7200,
+			"_uptime_by_uap": 600
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_current_ap_seconds{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 600`),
+				regexp.MustCompile(`unifi_stations_uptime_seconds_total{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 7200`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "wireless and wired stations with association time, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"assoc_time": 1500000000
+		},
+		{
+			"_id": "123456",
+			"is_wired": true,
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar",
+			"assoc_time": 1500000000
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`# TYPE unifi_stations_association_timestamp_seconds gauge\nunifi_stations_association_timestamp_seconds{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1.5e\+09\n# HELP`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "site station byte totals, three stations, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"rx_bytes": 10,
+			"tx_bytes": 20
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"rx_bytes": 100,
+			"tx_bytes": 200
+		},
+		{
+			"_id": "fedcba",
+			"is_wired": true,
+			"mac": "00:11:22:33:44:55",
+			"rx_bytes": 1000,
+			"tx_bytes": 2000
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_station_received_bytes{site="Default"} 1110`),
+				regexp.MustCompile(`unifi_site_station_transmitted_bytes{site="Default"} 2220`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "idle and active stations, default threshold",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"mac": "de:ad:be:ef:de:ad",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"idletime": 5
+		},
+		{
+			"_id": "123456",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"idletime": 600
+		},
+		{
+			"_id": "fedcba",
+			"mac": "00:11:22:33:44:55",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"idletime": 1800
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_idle_total{site="Default"} 2`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "idle and active stations, custom threshold",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"mac": "de:ad:be:ef:de:ad",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"idletime": 5
+		},
+		{
+			"_id": "123456",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"idletime": 600
+		},
+		{
+			"_id": "fedcba",
+			"mac": "00:11:22:33:44:55",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"idletime": 1800
+		}
+	]
+}
+`),
+			cfg: &Config{
+				IdleThreshold: time.Hour,
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_idle_total{site="Default"} 0`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one wireless station with transmit power, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"noise": -105,
+			"rssi": 35,
+			"tx_power": 20
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_rssi_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 35`),
+				regexp.MustCompile(`unifi_stations_noise_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} -105`),
+				regexp.MustCompile(`unifi_stations_transmit_power_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 20`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one station with receive, transmit, and combined rates, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"rx_rate": 144400,
+			"tx_rate": 300000,
+			"bytes-r": 12500
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_receive_rate_bps{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1.444e\+08`),
+				regexp.MustCompile(`unifi_stations_transmit_rate_bps{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 3e\+08`),
+				regexp.MustCompile(`unifi_stations_throughput_bytes_per_second{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 12500`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "transmit power headroom, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"tx_power": 14,
+			"max_txpower": 20
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar",
+			"tx_power": 20,
+			"max_txpower": 20
+		},
+		{
+			"_id": "fedcba",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "00:11:22:33:44:55",
+			"hostname": "baz",
+			"tx_power": 10
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_tx_power_headroom_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 0`),
+				regexp.MustCompile(`unifi_stations_tx_power_headroom_dbm{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 6\n`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "one wired station with uptime, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"is_wired": true,
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"uptime": 3600
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_uptime_seconds_total{ap_mac="",ap_name="",connection="wired",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 3600`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "wireless and wired stations with idle time and roam count, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"idletime": 42,
+			"roam_count": 3
+		},
+		{
+			"_id": "123456",
+			"is_wired": true,
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar",
+			"idletime": 7,
+			"roam_count": 0
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_idle_seconds{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 42\n# HELP`),
+				regexp.MustCompile(`unifi_stations_roam_count{ap_mac="",ap_name="",connection="wired",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 0`),
+				regexp.MustCompile(`unifi_stations_roam_count{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 3`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "stations with disagreeing guest classifications, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"is_guest": true,
+			"_is_guest_by_uap": false
+		},
+		{
+			"_id": "123456",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar",
+			"is_guest": false,
+			"_is_guest_by_uap": true
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_stations_guest{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 1`),
+				regexp.MustCompile(`unifi_stations_guest_by_ap{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 0`),
+				regexp.MustCompile(`unifi_stations_guest{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 0`),
+				regexp.MustCompile(`unifi_stations_guest_by_ap{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="bar",id="123456",site="Default",station_mac="ab:ad:1d:ea:ab:ad"} 1`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "wireless and wired stations with channel, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"channel": 36
+		},
+		{
+			"_id": "123456",
+			"is_wired": true,
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				// Only the wireless station has a channel
+				regexp.MustCompile(`# TYPE unifi_stations_channel gauge\nunifi_stations_channel{ap_mac="a0:a0:a0:a0:a0:a0",ap_name="",connection="wireless",hostname="foo",id="abcdef",site="Default",station_mac="de:ad:be:ef:de:ad"} 36\n# HELP`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
+		{
+			desc: "wireless stations on both bands and a wired station, one site",
+			input: strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abc",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:01",
+			"radio": "na"
+		},
+		{
+			"_id": "def",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:02",
+			"radio": "na"
+		},
+		{
+			"_id": "ghi",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:03",
+			"radio": "na"
+		},
+		{
+			"_id": "jkl",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:04",
+			"radio": "ng"
+		},
+		{
+			"_id": "mno",
+			"is_wired": true,
+			"mac": "de:ad:be:ef:de:05"
+		}
+	]
+}
+`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_site_band_steering_ratio{site="Default"} 0.75`),
+			},
+			sites: []*unifi.Site{{
+				Name:        "default",
+				Description: "Default",
+			}},
+		},
 	}
 
 	for i, tt := range tests {
 		t.Logf("[%02d] test %q", i, tt.desc)
 
-		out := testStationCollector(t, []byte(tt.input), tt.sites)
+		out := testStationCollector(t, []byte(tt.input), []byte(tt.devices), tt.sites, tt.cfg)
 
 		for j, m := range tt.matches {
 			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
@@ -213,13 +1172,222 @@ func TestStationCollector(t *testing.T) {
 	}
 }
 
-func testStationCollector(t *testing.T, input []byte, sites []*unifi.Site) []byte {
-	c, done := testUniFiClient(t, input)
+func TestStationCollectorWiredNoSignal(t *testing.T) {
+	out := testStationCollector(t, []byte(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"is_wired": true,
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"noise": -105,
+			"rssi": 35,
+			"tx_power": 20
+		}
+	]
+}
+`)), nil, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil)
+
+	for _, name := range []string{
+		"unifi_stations_rssi_dbm{",
+		"unifi_stations_noise_dbm{",
+		"unifi_stations_transmit_power_dbm{",
+	} {
+		if bytes.Contains(out, []byte(name)) {
+			t.Fatalf("unexpected signal series for wired station: %s", name)
+		}
+	}
+}
+
+func TestStationCollectorKnownClients(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/sta": []byte(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"ap_mac": "a0:a0:a0:a0:a0:a0",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo"
+		}
+	]
+}
+`)),
+		"list/user": []byte(strings.TrimSpace(`
+{
+	"data": [
+		{
+			"_id": "abcdef",
+			"mac": "de:ad:be:ef:de:ad",
+			"hostname": "foo",
+			"first_seen": 1500000000,
+			"last_seen": 1500000100
+		},
+		{
+			"_id": "123456",
+			"mac": "ab:ad:1d:ea:ab:ad",
+			"hostname": "bar",
+			"is_guest": true,
+			"first_seen": 1400000000,
+			"last_seen": 1400000100
+		},
+		{
+			"_id": "fedcba",
+			"mac": "00:11:22:33:44:55",
+			"oui": "Ubiquiti"
+		}
+	]
+}
+`)),
+	})
+	defer done()
+
+	out := testCollector(t, NewStationCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil))
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_stations{site="Default"} 1`),
+		regexp.MustCompile(`unifi_known_clients_total{site="Default"} 3`),
+	}
+
+	for i, m := range matches {
+		t.Logf("[%02d] match: %s", i, m.String())
+
+		if !m.Match(out) {
+			t.Fatal("output failed to match regex")
+		}
+	}
+}
+
+func TestStationCollectorKnownClientsUnavailable(t *testing.T) {
+	// Known clients cannot be decoded, but only their metric should be
+	// missing
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/sta":  testExporterEndpoints["stat/sta"],
+		"list/user": []byte(`{`),
+	})
+	defer done()
+
+	out := testCollector(t, NewStationCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil))
+
+	if want := []byte(`unifi_stations{site="Default"} 1`); !bytes.Contains(out, want) {
+		t.Fatalf("output missing station count:\n%s", string(out))
+	}
+
+	if bytes.Contains(out, []byte("unifi_known_clients_total")) {
+		t.Fatalf("unexpected known clients series:\n%s", string(out))
+	}
+}
+
+func TestStationCollectorSiteStationCountChange(t *testing.T) {
+	stations := func(n int) []byte {
+		data := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			data = append(data, fmt.Sprintf(`{"_id": "%d", "ap_mac": "a0:a0:a0:a0:a0:a0", "mac": "de:ad:be:ef:de:%02x"}`, i, i))
+		}
+
+		return []byte(fmt.Sprintf(`{"data": [%s]}`, strings.Join(data, ",")))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/sta": stations(10),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	sc := NewStationCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, nil)
+
+	var tests = []struct {
+		stations int
+		change   string
+	}{
+		{stations: 10, change: "0"},
+		{stations: 12, change: "2"},
+		{stations: 2, change: "-10"},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] %d stations", i, tt.stations)
+
+		endpoints["stat/sta"] = stations(tt.stations)
+		out := testCollector(t, sc)
+
+		m := regexp.MustCompile(fmt.Sprintf(`unifi_site_station_count_change{site="Default"} %s\n`, tt.change))
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+		}
+	}
+}
+
+func TestStationCollectorSiteStationBytesDisconnect(t *testing.T) {
+	stations := func(n int) []byte {
+		data := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			data = append(data, fmt.Sprintf(`{"_id": "%d", "ap_mac": "a0:a0:a0:a0:a0:a0", "mac": "de:ad:be:ef:de:%02x", "rx_bytes": 10}`, i, i))
+		}
+
+		return []byte(fmt.Sprintf(`{"data": [%s]}`, strings.Join(data, ",")))
+	}
+
+	endpoints := map[string][]byte{
+		"stat/sta": stations(10),
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	// Even with monotonic counters, the site sums must fall when stations
+	// disconnect
+	sc := NewStationCollector(c, []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, &Config{MonotonicCounters: true})
+
+	var tests = []struct {
+		stations int
+		bytes    string
+	}{
+		{stations: 10, bytes: "100"},
+		{stations: 1, bytes: "10"},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] %d stations", i, tt.stations)
+
+		endpoints["stat/sta"] = stations(tt.stations)
+		out := testCollector(t, sc)
+
+		m := regexp.MustCompile(fmt.Sprintf(`# TYPE unifi_site_station_received_bytes gauge\nunifi_site_station_received_bytes{site="Default"} %s\n`, tt.bytes))
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+		}
+	}
+}
+
+func testStationCollector(t *testing.T, input []byte, devices []byte, sites []*unifi.Site, cfg *Config) []byte {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/sta":    input,
+		"stat/device": devices,
+	})
 	defer done()
 
 	collector := NewStationCollector(
 		c,
 		sites,
+		cfg,
 	)
 
 	return testCollector(t, collector)
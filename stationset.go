@@ -0,0 +1,50 @@
+package unifiexporter
+
+import (
+	"net"
+	"sync"
+)
+
+// A stationSet tracks the distinct station MAC addresses seen across all
+// sites during a single collection.
+//
+// A nil *stationSet tracks nothing.
+type stationSet struct {
+	mu   sync.Mutex
+	macs map[string]struct{}
+}
+
+// newStationSet creates an empty stationSet.
+func newStationSet() *stationSet {
+	return &stationSet{
+		macs: make(map[string]struct{}),
+	}
+}
+
+// add records mac as seen.
+func (s *stationSet) add(mac net.HardwareAddr) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.macs[mac.String()] = struct{}{}
+}
+
+// reset discards all seen MAC addresses and returns the number of distinct
+// MAC addresses which were seen.
+func (s *stationSet) reset() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.macs)
+	s.macs = make(map[string]struct{})
+
+	return n
+}
@@ -0,0 +1,94 @@
+package unifiexporter
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A ttfbTracker tracks the most recent time to first byte of each UniFi
+// Controller API endpoint.
+type ttfbTracker struct {
+	mu   sync.Mutex
+	last map[string]time.Duration
+}
+
+// newTTFBTracker creates an empty ttfbTracker.
+func newTTFBTracker() *ttfbTracker {
+	return &ttfbTracker{
+		last: make(map[string]time.Duration),
+	}
+}
+
+// observe records d as the time to first byte of endpoint.
+func (t *ttfbTracker) observe(endpoint string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last[endpoint] = d
+}
+
+// snapshot returns a copy of the time to first byte of each endpoint.
+func (t *ttfbTracker) snapshot() map[string]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last := make(map[string]time.Duration, len(t.last))
+	for k, v := range t.last {
+		last[k] = v
+	}
+
+	return last
+}
+
+// A ttfbTransport is an http.RoundTripper which records the time to first
+// byte of each request in a ttfbTracker.
+type ttfbTransport struct {
+	rt   http.RoundTripper
+	ttfb *ttfbTracker
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ttfbTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		start time.Time
+		ttfb  time.Duration
+	)
+
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			ttfb = time.Since(start)
+		},
+	}
+
+	rt := t.rt
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	start = time.Now()
+	res, err := rt.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return nil, err
+	}
+
+	t.ttfb.observe(apiEndpoint(req.URL.Path), ttfb)
+	return res, nil
+}
+
+// apiEndpoint returns the UniFi Controller API endpoint for an HTTP request
+// path, with any site name removed, such as "stat/device".
+func apiEndpoint(path string) string {
+	path = strings.TrimPrefix(path, "/api/")
+
+	// Per-site endpoints are in the form "s/<site>/<endpoint>"
+	if strings.HasPrefix(path, "s/") {
+		if ss := strings.SplitN(path, "/", 3); len(ss) == 3 {
+			return ss[2]
+		}
+	}
+
+	return path
+}
@@ -3,11 +3,17 @@
 package unifiexporter
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mdlayher/unifi"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const (
@@ -22,10 +28,66 @@ const (
 // Prometheus. It implements the prometheus.Collector interface in order to
 // register with Prometheus.
 type Exporter struct {
-	mu         sync.Mutex
-	collectors []collector
-	sites      []*unifi.Site
-	clientFn   ClientFunc
+	mu       sync.Mutex
+	sites    []*unifi.Site
+	clientFn ClientFunc
+	cfg      *Config
+
+	// shards are sets of collectors which are collected concurrently.  By
+	// default, a single shard collects metrics for all sites.
+	shards [][]collector
+
+	// client is the UniFi client used by the current shards.
+	client *unifi.Client
+
+	// requestsMax is the highest number of concurrent requests made by
+	// any client previously used by the Exporter.
+	requestsMax int64
+
+	// ttfb tracks the time to first byte of each API endpoint for all
+	// clients used by the Exporter.
+	ttfb *ttfbTracker
+
+	// reloads is the number of times the configuration has been reloaded.
+	reloads int
+
+	// reloadOK reports whether the most recent reload succeeded.
+	reloadOK bool
+
+	// scrapeErrors is the number of times a collector has failed.
+	scrapeErrors int
+
+	// unreachable is the error which occurred when the UniFi Controller
+	// could not be reached during the most recent collection, if any.
+	unreachable error
+
+	// lastSamples is the number of samples emitted by the last successful
+	// collection.
+	lastSamples int
+
+	// refresh is set to 1 by Refresh so that the next collection bypasses
+	// cached responses.  It is accessed atomically, so that Refresh need
+	// not wait for a collection in progress.
+	refresh int32
+
+	// scrapes tracks recent collection results for each site, keyed by
+	// site description.
+	scrapes map[string]*scrapeWindow
+
+	configReloadsTotal      *prometheus.Desc
+	configLastReloadSuccess *prometheus.Desc
+	siteScrapeSuccessRatio  *prometheus.Desc
+	seriesTotal             *prometheus.Desc
+	samplesScraped          *prometheus.Desc
+	up                      *prometheus.Desc
+	scrapeDuration          *prometheus.Desc
+	scrapeErrorsTotal       *prometheus.Desc
+	lastSuccess             *prometheus.Desc
+	concurrentRequestsMax   *prometheus.Desc
+	ttfbSeconds             *prometheus.Desc
+	uniqueStations          *prometheus.Desc
+	dataFromCache           *prometheus.Desc
+	controllerTimezoneInfo  *prometheus.Desc
 }
 
 // Verify that the Exporter implements the prometheus.Collector interface.
@@ -38,17 +100,270 @@ type collector interface {
 	CollectError(chan<- prometheus.Metric) error
 }
 
+// A siteError is an error which occurred while collecting metrics for a
+// specific site.  collectors return siteErrors so that the Exporter can
+// attribute failures to individual sites.
+type siteError struct {
+	site *unifi.Site
+	err  error
+}
+
+func (e *siteError) Error() string {
+	return fmt.Sprintf("site %q: %v", e.site.Description, e.err)
+}
+
+// siteErrors is a set of siteErrors which occurred while collecting metrics
+// for multiple sites, so that every failing site can be attributed.
+type siteErrors []*siteError
+
+func (e siteErrors) Error() string {
+	ss := make([]string, 0, len(e))
+	for _, err := range e {
+		ss = append(ss, err.Error())
+	}
+
+	return strings.Join(ss, "; ")
+}
+
+// failedSites returns the descriptions of the sites which failed due to err.
+// If err is not attributed to specific sites, all of sites are considered
+// to have failed.
+func failedSites(sites []*unifi.Site, err error) []string {
+	switch err := err.(type) {
+	case *siteError:
+		return []string{err.site.Description}
+	case siteErrors:
+		ss := make([]string, 0, len(err))
+		for _, serr := range err {
+			ss = append(ss, serr.site.Description)
+		}
+		return ss
+	}
+
+	ss := make([]string, 0, len(sites))
+	for _, s := range sites {
+		ss = append(ss, s.Description)
+	}
+
+	return ss
+}
+
+// scrapeContext returns a context for the requests made by a single collection,
+// which is canceled after timeout if timeout is positive.
+func scrapeContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// forEachSite calls fn for each of sites, with at most n calls running
+// concurrently.  If any calls fail, the descriptor returned by the first
+// failing site, in the order of sites, is returned with a siteErrors
+// containing the error for each failing site.
+func forEachSite(sites []*unifi.Site, n int, fn func(s *unifi.Site) (*prometheus.Desc, error)) (*prometheus.Desc, error) {
+	type result struct {
+		desc *prometheus.Desc
+		err  error
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, n)
+		results = make([]result, len(sites))
+	)
+
+	for i, s := range sites {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, s *unifi.Site) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			desc, err := fn(s)
+			results[i] = result{desc: desc, err: err}
+		}(i, s)
+	}
+
+	wg.Wait()
+
+	var (
+		desc *prometheus.Desc
+		errs siteErrors
+	)
+
+	for i, r := range results {
+		if r.err == nil {
+			continue
+		}
+		if desc == nil {
+			desc = r.desc
+		}
+
+		serr, ok := r.err.(*siteError)
+		if !ok {
+			serr = &siteError{site: sites[i], err: r.err}
+		}
+		errs = append(errs, serr)
+	}
+
+	if len(errs) == 0 {
+		return nil, nil
+	}
+
+	return desc, errs
+}
+
 // A ClientFunc is a function which can return an authenticated UniFi client.
 // A ClientFunc is invoked by an Exporter whenever authentication against a UniFi
 // controller fails, such as when a user's privileges are revoked or the
 // authenticated session times out.
 type ClientFunc func() (*unifi.Client, error)
 
+// A ReloadFunc is a function which can return a fresh set of sites and a
+// ClientFunc, typically by re-reading a configuration file.  A ReloadFunc is
+// invoked by an Exporter when Exporter.Reload is called.
+type ReloadFunc func() ([]*unifi.Site, ClientFunc, error)
+
 // New creates a new Exporter which collects metrics from one or mote sites.
-func New(sites []*unifi.Site, fn ClientFunc) (*Exporter, error) {
+// If cfg is nil, a default configuration is used.
+func New(sites []*unifi.Site, fn ClientFunc, cfg *Config) (*Exporter, error) {
+	const (
+		subsystem = "exporter"
+	)
+
+	// Copy cfg so the caller's Config is not modified.
+	ecfg := *cfg.orDefault()
+	if err := ecfg.validate(); err != nil {
+		return nil, err
+	}
+
+	ecfg.logger = newErrorLogger(ecfg.ErrorLogInterval)
+	ecfg.counters = ecfg.counterTracker()
+	ecfg.uptimes = ecfg.uptimeTracker()
+	ecfg.channels = ecfg.channelTracker()
+	ecfg.deltas = ecfg.deltaTracker()
+	ecfg.states = ecfg.stateTracker()
+	ecfg.averages = ecfg.averageTracker()
+	ecfg.trends = ecfg.trendTracker()
+	ecfg.events = ecfg.eventTracker()
+	ecfg.endpoints = newEndpointTracker()
+	ecfg.stations = newStationSet()
+	ecfg.controller = &controllerState{}
+	ecfg.cache = newResponseCache(ecfg.CacheTTL)
+
 	e := &Exporter{
 		clientFn: fn,
 		sites:    sites,
+		cfg:      &ecfg,
+
+		// The initial configuration is considered a successful load.
+		reloadOK: true,
+
+		scrapes: make(map[string]*scrapeWindow),
+		ttfb:    newTTFBTracker(),
+
+		configReloadsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "config_reloads_total"),
+			"Number of times the exporter has attempted to reload its configuration",
+			nil,
+			nil,
+		),
+
+		configLastReloadSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "config_last_reload_success"),
+			"Whether the last configuration reload was successful (1) or not (0)",
+			nil,
+			nil,
+		),
+
+		siteScrapeSuccessRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "site", "scrape_success_ratio"),
+			"Ratio of successful metrics collections for a site over its most recent collections",
+			[]string{"site"},
+			nil,
+		),
+
+		seriesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "series_total"),
+			"Number of metric series emitted by the exporter in the current scrape, excluding this one and the samples count",
+			nil,
+			nil,
+		),
+
+		samplesScraped: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "scrape_samples_scraped"),
+			"Number of samples emitted by the exporter in the last successful scrape, excluding this one and the series count",
+			nil,
+			nil,
+		),
+
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Whether the last collection of metrics from the UniFi Controller was successful (1) or not (0)",
+			nil,
+			nil,
+		),
+
+		scrapeDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "scrape", "duration_seconds"),
+			"Time taken to collect metrics from the UniFi Controller, including any reauthentication",
+			nil,
+			nil,
+		),
+
+		scrapeErrorsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "scrape", "errors_total"),
+			"Number of times a collector has failed to collect metrics from the UniFi Controller",
+			nil,
+			nil,
+		),
+
+		lastSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "controller", "last_success_timestamp_seconds"),
+			"UNIX timestamp of the last successful query of each UniFi Controller API endpoint",
+			[]string{"endpoint"},
+			nil,
+		),
+
+		concurrentRequestsMax: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "controller", "concurrent_requests_max"),
+			"Highest number of concurrent requests made to the UniFi Controller",
+			nil,
+			nil,
+		),
+
+		ttfbSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "controller", "ttfb_seconds"),
+			"Time to first byte of the most recent response from each UniFi Controller API endpoint",
+			[]string{"endpoint"},
+			nil,
+		),
+
+		uniqueStations: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "controller", "unique_stations"),
+			"Number of distinct stations connected to any site, counting stations which appear in multiple sites once",
+			nil,
+			nil,
+		),
+
+		dataFromCache: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "data_from_cache"),
+			"Whether any data in the most recent collection was served from the response cache rather than retrieved from the UniFi Controller",
+			nil,
+			nil,
+		),
+
+		controllerTimezoneInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "controller", "timezone_info"),
+			"Timezone configured on the UniFi Controller, used to interpret controller-relative times",
+			[]string{"timezone"},
+			nil,
+		),
 	}
 
 	if err := e.initClient(); err != nil {
@@ -64,7 +379,27 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	for _, cc := range e.collectors {
+	ch <- e.configReloadsTotal
+	ch <- e.configLastReloadSuccess
+	ch <- e.siteScrapeSuccessRatio
+	ch <- e.seriesTotal
+	ch <- e.samplesScraped
+	ch <- e.up
+	ch <- e.scrapeDuration
+	ch <- e.scrapeErrorsTotal
+	ch <- e.lastSuccess
+	ch <- e.concurrentRequestsMax
+	ch <- e.ttfbSeconds
+	ch <- e.uniqueStations
+	ch <- e.dataFromCache
+	ch <- e.controllerTimezoneInfo
+
+	// Every shard produces the same descriptors, so only the first is used
+	if len(e.shards) == 0 {
+		return
+	}
+
+	for _, cc := range e.shards[0] {
 		cc.Describe(ch)
 	}
 }
@@ -76,16 +411,383 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	for _, cc := range e.collectors {
-		if err := cc.CollectError(ch); err == nil {
-			continue
+	// Count each metric and its samples as it is sent, so the number of
+	// series and samples can be reported once collection is complete
+	type count struct {
+		series, samples int
+	}
+
+	mc := make(chan prometheus.Metric)
+	nC := make(chan count)
+	go func() {
+		var n count
+		for m := range mc {
+			// Collectors report failures as invalid metrics, which would
+			// fail the entire scrape.  Failures are reported by unifi_up
+			// instead, so invalid metrics are dropped here.
+			var dm dto.Metric
+			if err := m.Write(&dm); err != nil {
+				continue
+			}
+
+			ch <- m
+			n.series++
+			n.samples += samples(&dm)
 		}
 
-		if err := e.initClient(); err != nil {
-			log.Printf("[ERROR] could not initialize UniFi client: %v", err)
-			return
+		nC <- n
+	}()
+
+	start := time.Now()
+	ok := e.collect(mc)
+
+	mc <- prometheus.MustNewConstMetric(
+		e.scrapeDuration,
+		prometheus.GaugeValue,
+		time.Since(start).Seconds(),
+	)
+	mc <- prometheus.MustNewConstMetric(
+		e.scrapeErrorsTotal,
+		prometheus.CounterValue,
+		float64(e.scrapeErrors),
+	)
+	close(mc)
+
+	n := <-nC
+	if ok {
+		e.lastSamples = n.samples
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		e.seriesTotal,
+		prometheus.GaugeValue,
+		float64(n.series),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		e.samplesScraped,
+		prometheus.GaugeValue,
+		float64(e.lastSamples),
+	)
+}
+
+// collect performs the work for Collect, and reports whether all collectors
+// succeeded.
+//
+// collect must be called with e's mutex locked.
+func (e *Exporter) collect(ch chan<- prometheus.Metric) bool {
+	if atomic.SwapInt32(&e.refresh, 0) == 1 {
+		e.cfg.cache.reset()
+	}
+
+	// Report messages which were suppressed during earlier collections once
+	// this collection has logged its own
+	defer e.cfg.logger.flush()
+
+	e.collectReloads(ch)
+
+	failed := make(map[string]bool)
+	defer e.collectSiteScrapes(ch, failed)
+
+	e.collectController(ch)
+
+	errs := e.collectShards(ch)
+	e.scrapeErrors += len(errs)
+	e.collectLastSuccess(ch)
+	e.collectConcurrentRequests(ch)
+	e.collectTTFB(ch)
+	e.collectUniqueStations(ch)
+	e.collectDataFromCache(ch)
+
+	var up float64
+	if len(errs) == 0 {
+		up = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		e.up,
+		prometheus.GaugeValue,
+		up,
+	)
+
+	if len(errs) == 0 {
+		e.unreachable = nil
+
+		// Series from failed sites were not seen during this collection,
+		// so tracked series are only evicted after a complete collection
+		e.cfg.sweepTrackers()
+		return true
+	}
+
+	for _, err := range errs {
+		for _, s := range failedSites(e.sites, err) {
+			failed[s] = true
 		}
 	}
+
+	// If a fresh session cannot be established either, the UniFi Controller
+	// is considered unreachable until a later collection succeeds
+	e.unreachable = e.initClient()
+	if e.unreachable != nil {
+		e.cfg.logger.Printf("[ERROR] could not initialize UniFi client: %v", e.unreachable)
+	}
+
+	return false
+}
+
+// Refresh causes the next collection to retrieve all data from the UniFi
+// Controller, rather than serving any cached responses.
+func (e *Exporter) Refresh() {
+	atomic.StoreInt32(&e.refresh, 1)
+}
+
+// Err returns the error which occurred if the UniFi Controller could not be
+// reached during the most recent collection, or nil if it was reachable.
+func (e *Exporter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.unreachable
+}
+
+// collectController retrieves UniFi Controller-wide data once for use by the
+// collectors of every shard, and collects controller metrics from it.
+// Failures are logged rather than failing the collection, since only a few
+// metrics depend on this data.
+//
+// collectController must be called with e's mutex locked.
+func (e *Exporter) collectController(ch chan<- prometheus.Metric) {
+	ctx, cancel := scrapeContext(e.cfg.ScrapeTimeout)
+	defer cancel()
+
+	var site string
+	if len(e.sites) > 0 {
+		site = e.sites[0].Name
+	}
+
+	info := fetchControllerInfo(ctx, e.client, site, e.cfg.cache, e.cfg.endpoints, e.cfg.logger)
+	e.cfg.controller.set(info)
+
+	if info.sysInfo != nil && info.sysInfo.Timezone != "" {
+		ch <- prometheus.MustNewConstMetric(
+			e.controllerTimezoneInfo,
+			prometheus.GaugeValue,
+			1,
+			info.sysInfo.Timezone,
+		)
+	}
+}
+
+// collectShards collects metrics from each of e's shards concurrently, and
+// returns any errors which occur.
+//
+// collectShards must be called with e's mutex locked.
+func (e *Exporter) collectShards(ch chan<- prometheus.Metric) []error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	wg.Add(len(e.shards))
+	for _, shard := range e.shards {
+		go func(shard []collector) {
+			defer wg.Done()
+
+			for _, cc := range shard {
+				if err := cc.CollectError(ch); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}(shard)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// collectSiteScrapes records the result of a collection for each site, using
+// failed to determine which sites failed, and collects the scrape success
+// ratio for each site.
+//
+// collectSiteScrapes must be called with e's mutex locked.
+func (e *Exporter) collectSiteScrapes(ch chan<- prometheus.Metric, failed map[string]bool) {
+	for _, s := range e.sites {
+		w, ok := e.scrapes[s.Description]
+		if !ok {
+			w = &scrapeWindow{}
+			e.scrapes[s.Description] = w
+		}
+
+		w.add(!failed[s.Description])
+
+		ch <- prometheus.MustNewConstMetric(
+			e.siteScrapeSuccessRatio,
+			prometheus.GaugeValue,
+			w.ratio(),
+			s.Description,
+		)
+	}
+}
+
+// Reload invokes fn to retrieve a new set of sites and a new ClientFunc, and
+// reauthenticates against the UniFi controller using them.  If reloading
+// fails, the Exporter continues to use its previous configuration.
+func (e *Exporter) Reload(fn ReloadFunc) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	err := e.reload(fn)
+
+	e.reloads++
+	e.reloadOK = err == nil
+
+	return err
+}
+
+// reload performs the work for Reload.
+//
+// reload must be called with e's mutex locked.
+func (e *Exporter) reload(fn ReloadFunc) error {
+	sites, clientFn, err := fn()
+	if err != nil {
+		return err
+	}
+
+	prevSites, prevClientFn := e.sites, e.clientFn
+	e.sites, e.clientFn = sites, clientFn
+
+	if err := e.initClient(); err != nil {
+		e.sites, e.clientFn = prevSites, prevClientFn
+		return err
+	}
+
+	return nil
+}
+
+// collectReloads collects metrics regarding configuration reloads.
+//
+// collectReloads must be called with e's mutex locked.
+func (e *Exporter) collectReloads(ch chan<- prometheus.Metric) {
+	var success float64
+	if e.reloadOK {
+		success = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		e.configReloadsTotal,
+		prometheus.CounterValue,
+		float64(e.reloads),
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		e.configLastReloadSuccess,
+		prometheus.GaugeValue,
+		success,
+	)
+}
+
+// collectLastSuccess collects the time of the last successful query of each
+// UniFi Controller API endpoint.
+//
+// collectLastSuccess must be called with e's mutex locked.
+func (e *Exporter) collectLastSuccess(ch chan<- prometheus.Metric) {
+	for endpoint, t := range e.cfg.endpoints.snapshot() {
+		ch <- prometheus.MustNewConstMetric(
+			e.lastSuccess,
+			prometheus.GaugeValue,
+			float64(t.Unix()),
+			endpoint,
+		)
+	}
+}
+
+// collectConcurrentRequests collects the highest number of concurrent requests
+// made to the UniFi Controller.
+//
+// collectConcurrentRequests must be called with e's mutex locked.
+func (e *Exporter) collectConcurrentRequests(ch chan<- prometheus.Metric) {
+	e.updateRequestsMax()
+
+	ch <- prometheus.MustNewConstMetric(
+		e.concurrentRequestsMax,
+		prometheus.GaugeValue,
+		float64(e.requestsMax),
+	)
+}
+
+// collectUniqueStations collects the number of distinct stations seen by all
+// shards during the most recent collection.
+//
+// collectUniqueStations must be called with e's mutex locked.
+func (e *Exporter) collectUniqueStations(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		e.uniqueStations,
+		prometheus.GaugeValue,
+		float64(e.cfg.stations.reset()),
+	)
+}
+
+// collectDataFromCache collects whether any cached response was served during
+// the most recent collection.
+//
+// collectDataFromCache must be called with e's mutex locked.
+func (e *Exporter) collectDataFromCache(ch chan<- prometheus.Metric) {
+	var v float64
+	if e.cfg.cache.served() {
+		v = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		e.dataFromCache,
+		prometheus.GaugeValue,
+		v,
+	)
+}
+
+// collectTTFB collects the most recent time to first byte of each UniFi
+// Controller API endpoint.
+//
+// collectTTFB must be called with e's mutex locked.
+func (e *Exporter) collectTTFB(ch chan<- prometheus.Metric) {
+	for endpoint, d := range e.ttfb.snapshot() {
+		ch <- prometheus.MustNewConstMetric(
+			e.ttfbSeconds,
+			prometheus.GaugeValue,
+			d.Seconds(),
+			endpoint,
+		)
+	}
+}
+
+// updateRequestsMax raises e's concurrent requests high-water mark to that of
+// its current client, if higher.
+//
+// updateRequestsMax must be called with e's mutex locked.
+func (e *Exporter) updateRequestsMax() {
+	if e.client == nil {
+		return
+	}
+
+	if n := e.client.MaxConcurrentRequests(); n > e.requestsMax {
+		e.requestsMax = n
+	}
+}
+
+// newCollectors creates each of the collectors used by an Exporter to collect
+// metrics for sites.
+func newCollectors(c *unifi.Client, sites []*unifi.Site, cfg *Config) []collector {
+	return []collector{
+		NewDeviceCollector(c, sites, cfg),
+		NewStationCollector(c, sites, cfg),
+		NewGuestCollector(c, sites, cfg),
+		NewGatewayCollector(c, sites, cfg),
+		NewAlarmCollector(c, sites, cfg),
+		NewEventCollector(c, sites, cfg),
+		NewDPICollector(c, sites, cfg),
+		NewSiteCollector(sites, cfg),
+	}
 }
 
 // initClient sets up collectors for the Exporter, authenticating against
@@ -98,9 +800,32 @@ func (e *Exporter) initClient() error {
 		return err
 	}
 
-	e.collectors = []collector{
-		NewDeviceCollector(c, e.sites),
-		NewStationCollector(c, e.sites),
+	// Responses cached using the previous session are discarded
+	e.cfg.cache.reset()
+
+	// Retain the high-water mark of the previous client before replacing it
+	e.updateRequestsMax()
+	e.client = c
+
+	// Trace requests made by the client, replacing any previous tracing so
+	// that a reused client is not traced twice
+	hc := c.HTTPClient()
+	rt := hc.Transport
+	if t, ok := rt.(*ttfbTransport); ok {
+		rt = t.rt
+	}
+	hc.Transport = &ttfbTransport{
+		rt:   rt,
+		ttfb: e.ttfb,
+	}
+
+	if !e.cfg.ShardBySite || len(e.sites) == 0 {
+		e.shards = [][]collector{newCollectors(c, e.sites, e.cfg)}
+	} else {
+		e.shards = make([][]collector, 0, len(e.sites))
+		for _, s := range e.sites {
+			e.shards = append(e.shards, newCollectors(c, []*unifi.Site{s}, e.cfg))
+		}
 	}
 
 	log.Println("[INFO] successfully authenticated to UniFi controller")
@@ -1,15 +1,1586 @@
 package unifiexporter
 
 import (
-	"io/ioutil"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mdlayher/unifi"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
+func TestNewInvalidConfig(t *testing.T) {
+	var tests = []struct {
+		desc string
+		cfg  *Config
+		err  string
+	}{
+		{
+			desc: "negative cache TTL",
+			cfg:  &Config{CacheTTL: -1},
+			err:  "invalid cache TTL",
+		},
+		{
+			desc: "negative scrape timeout",
+			cfg:  &Config{ScrapeTimeout: -1},
+			err:  "invalid scrape timeout",
+		},
+		{
+			desc: "negative error log interval",
+			cfg:  &Config{ErrorLogInterval: -time.Minute},
+			err:  "invalid error log interval",
+		},
+	}
+
+	clientFn := func() (*unifi.Client, error) {
+		return nil, errors.New("client should not be created")
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		_, err := New(nil, clientFn, tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v", tt.err, err)
+		}
+	}
+}
+
+func TestExporterReload(t *testing.T) {
+	c, done := testUniFiClient(t, []byte(`{"data":[]}`))
+	defer done()
+
+	clientFn := func() (*unifi.Client, error) {
+		return c, nil
+	}
+
+	sites := []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}
+
+	e, err := New(sites, clientFn, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	var tests = []struct {
+		desc    string
+		fn      ReloadFunc
+		ok      bool
+		matches []*regexp.Regexp
+	}{
+		{
+			desc: "successful reload",
+			fn: func() ([]*unifi.Site, ClientFunc, error) {
+				return sites, clientFn, nil
+			},
+			ok: true,
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_exporter_config_reloads_total 1`),
+				regexp.MustCompile(`unifi_exporter_config_last_reload_success 1`),
+			},
+		},
+		{
+			desc: "failed reload",
+			fn: func() ([]*unifi.Site, ClientFunc, error) {
+				return nil, nil, errors.New("bad config")
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_exporter_config_reloads_total 2`),
+				regexp.MustCompile(`unifi_exporter_config_last_reload_success 0`),
+			},
+		},
+		{
+			desc: "failed authentication during reload",
+			fn: func() ([]*unifi.Site, ClientFunc, error) {
+				return sites, func() (*unifi.Client, error) {
+					return nil, errors.New("bad credentials")
+				}, nil
+			},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_exporter_config_reloads_total 3`),
+				regexp.MustCompile(`unifi_exporter_config_last_reload_success 0`),
+			},
+		},
+		{
+			desc: "successful reload after failure",
+			fn: func() ([]*unifi.Site, ClientFunc, error) {
+				return sites, clientFn, nil
+			},
+			ok: true,
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_exporter_config_reloads_total 4`),
+				regexp.MustCompile(`unifi_exporter_config_last_reload_success 1`),
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		if err := e.Reload(tt.fn); (err == nil) != tt.ok {
+			t.Fatalf("unexpected reload error: %v", err)
+		}
+
+		out := testCollector(t, e)
+
+		for j, m := range tt.matches {
+			t.Logf("\t[%02d:%02d] match: %s", i, j, m.String())
+
+			if !m.Match(out) {
+				t.Fatal("\toutput failed to match regex")
+			}
+		}
+	}
+}
+
+func TestExporterSiteScrapeSuccessRatio(t *testing.T) {
+	var fail bool
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	e, err := New([]*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	var tests = []struct {
+		desc  string
+		fail  bool
+		match *regexp.Regexp
+	}{
+		{
+			desc:  "successful scrape",
+			match: regexp.MustCompile(`unifi_site_scrape_success_ratio{site="Default"} 1`),
+		},
+		{
+			desc:  "failed scrape",
+			fail:  true,
+			match: regexp.MustCompile(`unifi_site_scrape_success_ratio{site="Default"} 0.5`),
+		},
+		{
+			desc: "successful scrape after failure",
+		},
+		{
+			desc:  "second successful scrape after failure",
+			match: regexp.MustCompile(`unifi_site_scrape_success_ratio{site="Default"} 0.75`),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		fail = tt.fail
+		out := testCollector(t, e)
+
+		if tt.match != nil && !tt.match.Match(out) {
+			t.Fatalf("output failed to match regex: %s", tt.match)
+		}
+	}
+}
+
+func TestExporterUp(t *testing.T) {
+	var fail bool
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	e, err := New([]*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}, func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	var tests = []struct {
+		desc  string
+		fail  bool
+		match *regexp.Regexp
+	}{
+		{
+			desc:  "successful scrape",
+			match: regexp.MustCompile(`unifi_up 1\n`),
+		},
+		{
+			desc:  "failed scrape",
+			fail:  true,
+			match: regexp.MustCompile(`unifi_up 0\n`),
+		},
+		{
+			desc:  "successful scrape after failure",
+			match: regexp.MustCompile(`unifi_up 1\n`),
+		},
+		{
+			// Each collector which failed is counted
+			desc:  "scrape errors persist",
+			match: regexp.MustCompile(`unifi_scrape_errors_total 7\n`),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		fail = tt.fail
+		out := testCollector(t, e)
+
+		if !tt.match.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", tt.match, string(out))
+		}
+	}
+}
+
+func TestExporterErr(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/device": []byte(`{`),
+	})
+	defer done()
+
+	var unreachable error
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, unreachable
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	var tests = []struct {
+		desc        string
+		unreachable error
+		ok          bool
+	}{
+		{
+			// Reauthentication succeeds, so the controller is reachable
+			desc: "failed scrape, reachable controller",
+			ok:   true,
+		},
+		{
+			desc:        "failed scrape, unreachable controller",
+			unreachable: errors.New("connection refused"),
+		},
+		{
+			desc: "controller reachable again",
+			ok:   true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		unreachable = tt.unreachable
+		_ = testCollector(t, e)
+
+		if err := e.Err(); tt.ok && err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		} else if !tt.ok && err != tt.unreachable {
+			t.Fatalf("unexpected error:\n- want: %v\n-  got: %v", tt.unreachable, err)
+		}
+	}
+}
+
+func TestExporterScrapeMetrics(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, testExporterEndpoints)
+	defer done()
+
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	out := testCollector(t, e)
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_scrape_duration_seconds \d`),
+		regexp.MustCompile(`unifi_scrape_errors_total 0\n`),
+	}
+
+	for _, m := range matches {
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+		}
+	}
+}
+
+func TestExporterLastSuccess(t *testing.T) {
+	endpoints := map[string][]byte{
+		"stat/device": testExporterEndpoints["stat/device"],
+		"stat/sta":    testExporterEndpoints["stat/sta"],
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	var tests = []struct {
+		desc    string
+		now     int64
+		device  []byte
+		matches []*regexp.Regexp
+	}{
+		{
+			desc:   "all endpoints succeed",
+			now:    1000,
+			device: testExporterEndpoints["stat/device"],
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="self/sites"} 1000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/device"} 1000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/sta"} 1000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/guest"} 1000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/health"} 1000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="list/alarm"} 1000\n`),
+			},
+		},
+		{
+			desc:   "devices endpoint fails",
+			now:    2000,
+			device: []byte(`{`),
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="self/sites"} 2000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/device"} 1000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/sta"} 2000\n`),
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/guest"} 2000\n`),
+			},
+		},
+		{
+			desc:   "devices endpoint recovers",
+			now:    3000,
+			device: testExporterEndpoints["stat/device"],
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{endpoint="stat/device"} 3000\n`),
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		now := time.Unix(tt.now, 0)
+		e.cfg.endpoints.now = func() time.Time { return now }
+		endpoints["stat/device"] = tt.device
+
+		out := testCollector(t, e)
+
+		for _, m := range tt.matches {
+			if !m.Match(out) {
+				t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+			}
+		}
+	}
+}
+
+func TestExporterCache(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+		for k, v := range testExporterEndpoints {
+			if strings.HasSuffix(r.URL.Path, k) {
+				mu.Lock()
+				requests[k]++
+				mu.Unlock()
+
+				_, _ = w.Write(v)
+				return
+			}
+		}
+
+		if strings.HasSuffix(r.URL.Path, "stat/sysinfo") {
+			mu.Lock()
+			requests["stat/sysinfo"]++
+			mu.Unlock()
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, nil
+	}, &Config{CacheTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	if err := e.initClient(); err != nil {
+		t.Fatalf("failed to initialize client: %v", err)
+	}
+
+	now := time.Unix(1000, 0)
+	e.cfg.cache.now = func() time.Time { return now }
+
+	var tests = []struct {
+		desc      string
+		advance   time.Duration
+		reauth    bool
+		refresh   bool
+		devices   int
+		stations  int
+		fromCache bool
+	}{
+		{
+			desc:     "first collection queries controller",
+			devices:  1,
+			stations: 1,
+		},
+		{
+			desc:      "second collection within TTL is cached",
+			advance:   30 * time.Second,
+			devices:   1,
+			stations:  1,
+			fromCache: true,
+		},
+		{
+			desc:     "collection after TTL queries controller",
+			advance:  time.Minute,
+			devices:  2,
+			stations: 2,
+		},
+		{
+			desc:     "reauthentication invalidates cache",
+			reauth:   true,
+			devices:  3,
+			stations: 3,
+		},
+		{
+			desc:      "collection within TTL after reauthentication is cached",
+			devices:   3,
+			stations:  3,
+			fromCache: true,
+		},
+		{
+			desc:     "refresh bypasses cache once",
+			refresh:  true,
+			devices:  4,
+			stations: 4,
+		},
+		{
+			desc:      "collection after refresh is cached",
+			devices:   4,
+			stations:  4,
+			fromCache: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		now = now.Add(tt.advance)
+		if tt.reauth {
+			if err := e.initClient(); err != nil {
+				t.Fatalf("failed to initialize client: %v", err)
+			}
+		}
+		if tt.refresh {
+			e.Refresh()
+		}
+
+		out := testCollector(t, e)
+
+		mu.Lock()
+		devices, stations := requests["stat/device"], requests["stat/sta"]
+		sysInfo := requests["stat/sysinfo"]
+		mu.Unlock()
+
+		// sysinfo reports the controller's current time, so it is retrieved
+		// by every collection
+		if want, got := i+1, sysInfo; want != got {
+			t.Fatalf("unexpected number of sysinfo requests:\n- want: %d\n-  got: %d", want, got)
+		}
+
+		if want, got := tt.devices, devices; want != got {
+			t.Fatalf("unexpected number of device requests:\n- want: %d\n-  got: %d", want, got)
+		}
+		if want, got := tt.stations, stations; want != got {
+			t.Fatalf("unexpected number of station requests:\n- want: %d\n-  got: %d", want, got)
+		}
+
+		want := []byte("unifi_data_from_cache 0\n")
+		if tt.fromCache {
+			want = []byte("unifi_data_from_cache 1\n")
+		}
+		if !bytes.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", string(want), string(out))
+		}
+	}
+}
+
+func TestExporterSharesResponsesWithinCollection(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		devices int
+	)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+		if strings.HasSuffix(r.URL.Path, "stat/device") {
+			mu.Lock()
+			devices++
+			mu.Unlock()
+		}
+
+		for k, v := range testExporterEndpoints {
+			if strings.HasSuffix(r.URL.Path, k) {
+				_, _ = w.Write(v)
+				return
+			}
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	// Caching is disabled, but the device and station collectors should
+	// still share a single request for devices in each collection
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		out := testCollector(t, e)
+
+		mu.Lock()
+		n := devices
+		mu.Unlock()
+
+		if want, got := i, n; want != got {
+			t.Fatalf("unexpected number of device requests:\n- want: %d\n-  got: %d", want, got)
+		}
+		if want := []byte("unifi_data_from_cache 0\n"); !bytes.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", string(want), string(out))
+		}
+	}
+}
+
+func TestExporterTTFB(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	// Delay the response headers so that time to first byte is measurable
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	// Reauthentication must not trace the same client twice
+	if err := e.initClient(); err != nil {
+		t.Fatalf("failed to initialize client: %v", err)
+	}
+	if _, ok := c.HTTPClient().Transport.(*ttfbTransport).rt.(*ttfbTransport); ok {
+		t.Fatal("client transport was traced more than once")
+	}
+
+	out := testCollector(t, e)
+
+	for _, endpoint := range []string{"self/sites", "stat/device", "stat/sta"} {
+		re := regexp.MustCompile(fmt.Sprintf(`unifi_controller_ttfb_seconds{endpoint=%q} (\S+)\n`, endpoint))
+
+		m := re.FindSubmatch(out)
+		if m == nil {
+			t.Fatalf("output missing time to first byte for endpoint %q:\n%s", endpoint, string(out))
+		}
+
+		got, err := strconv.ParseFloat(string(m[1]), 64)
+		if err != nil {
+			t.Fatalf("failed to parse time to first byte: %v", err)
+		}
+
+		if got < delay.Seconds() {
+			t.Fatalf("unexpected time to first byte for endpoint %q: %v < %v", endpoint, got, delay.Seconds())
+		}
+	}
+}
+
+func TestAPIEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/s/default/stat/device", want: "stat/device"},
+		{path: "/api/s/default/cmd/devmgr", want: "cmd/devmgr"},
+		{path: "/api/self/sites", want: "self/sites"},
+		{path: "/api/login", want: "login"},
+	}
+
+	for _, tt := range tests {
+		if got := apiEndpoint(tt.path); tt.want != got {
+			t.Fatalf("unexpected endpoint for %q:\n- want: %q\n-  got: %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestExporterShardBySite(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, testExporterEndpoints)
+	defer done()
+
+	clientFn := func() (*unifi.Client, error) {
+		return c, nil
+	}
+
+	sites := testExporterSites(8)
+
+	collect := func(cfg *Config) []byte {
+		e, err := New(sites, clientFn, cfg)
+		if err != nil {
+			t.Fatalf("failed to create exporter: %v", err)
+		}
+
+		// Scrape duration, timestamps, and request concurrency vary
+		// between collections, so they are not compared
+		out := scrapeDurationRE.ReplaceAll(testCollector(t, e), nil)
+		out = concurrentRequestsRE.ReplaceAll(out, nil)
+		out = ttfbRE.ReplaceAll(out, nil)
+		return lastSuccessRE.ReplaceAll(out, nil)
+	}
+
+	serial := collect(nil)
+	sharded := collect(&Config{ShardBySite: true})
+
+	if !bytes.Equal(serial, sharded) {
+		t.Fatalf("unexpected sharded output:\n- want:\n%s\n-  got:\n%s", string(serial), string(sharded))
+	}
+
+	// Sanity check that metrics for every site were collected
+	for _, s := range sites {
+		m := regexp.MustCompile(fmt.Sprintf(`unifi_devices{site="%s"} 1`, s.Description))
+		if !m.Match(sharded) {
+			t.Fatalf("output failed to match regex: %s", m)
+		}
+	}
+}
+
+func BenchmarkExporterCollect(b *testing.B) {
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Simulate the latency of a busy UniFi Controller
+		time.Sleep(1 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		for k, v := range testExporterEndpoints {
+			if strings.HasSuffix(r.URL.Path, k) {
+				_, _ = w.Write(v)
+				return
+			}
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		b.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	clientFn := func() (*unifi.Client, error) {
+		return c, nil
+	}
+
+	var tests = []struct {
+		name string
+		cfg  *Config
+	}{
+		{
+			name: "serial",
+		},
+		{
+			name: "sharded",
+			cfg:  &Config{ShardBySite: true},
+		},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			e, err := New(testExporterSites(16), clientFn, tt.cfg)
+			if err != nil {
+				b.Fatalf("failed to create exporter: %v", err)
+			}
+
+			ch := make(chan prometheus.Metric)
+			go func() {
+				for range ch {
+				}
+			}()
+			defer close(ch)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.Collect(ch)
+			}
+		})
+	}
+}
+
+// scrapeDurationRE matches the scrape duration series in an Exporter's output.
+var scrapeDurationRE = regexp.MustCompile(`unifi_scrape_duration_seconds .*\n`)
+
+// lastSuccessRE matches the endpoint last success series in an Exporter's
+// output.
+var lastSuccessRE = regexp.MustCompile(`unifi_controller_last_success_timestamp_seconds{.*\n`)
+
+// concurrentRequestsRE matches the concurrent requests high-water mark series
+// in an Exporter's output.
+var concurrentRequestsRE = regexp.MustCompile(`unifi_controller_concurrent_requests_max .*\n`)
+
+// ttfbRE matches the endpoint time to first byte series in an Exporter's
+// output.
+var ttfbRE = regexp.MustCompile(`unifi_controller_ttfb_seconds{.*\n`)
+
+// testExporterEndpoints are API responses used to test an Exporter which
+// collects metrics for many sites.
+var testExporterEndpoints = map[string][]byte{
+	"stat/device": []byte(`{"data":[{"_id":"abc","adopted":true,"inform_ip":"192.168.1.1","name":"ABC","ethernet_table":[{"mac":"de:ad:be:ef:de:ad"}],"stat":{"rx_bytes":80,"tx_bytes":20},"uplink":{}}]}`),
+	"stat/sta":    []byte(`{"data":[{"_id":"abcdef","ap_mac":"de:ad:be:ef:de:ad","mac":"a0:a0:a0:a0:a0:a0","hostname":"foo","rssi":40,"rx_bytes":10}]}`),
+	"stat/guest":  []byte(`{"data":[{"_id":"ghi","mac":"b0:b0:b0:b0:b0:b0","start":1000,"end":3000}]}`),
+	"stat/health": []byte(`{"data":[{"subsystem":"www","status":"ok"}]}`),
+}
+
+// testExporterSites creates n sites for use with an Exporter.
+func testExporterSites(n int) []*unifi.Site {
+	sites := make([]*unifi.Site, 0, n)
+	for i := 0; i < n; i++ {
+		sites = append(sites, &unifi.Site{
+			Name:        fmt.Sprintf("site%d", i),
+			Description: fmt.Sprintf("Site %d", i),
+		})
+	}
+
+	return sites
+}
+
+func TestExporterSeriesTotal(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, testExporterEndpoints)
+	defer done()
+
+	clientFn := func() (*unifi.Client, error) {
+		return c, nil
+	}
+
+	e, err := New(testExporterSites(2), clientFn, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	out := testCollector(t, e)
+
+	// Count each series in the output, other than the series and samples
+	// counts themselves
+	var series int
+	for _, l := range strings.Split(string(out), "\n") {
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "unifi_exporter_series_total") || strings.HasPrefix(l, "unifi_exporter_scrape_samples_scraped") {
+			continue
+		}
+
+		series++
+	}
+
+	m := regexp.MustCompile(fmt.Sprintf(`unifi_exporter_series_total %d\n`, series))
+	if !m.Match(out) {
+		t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+	}
+}
+
+func TestExporterScrapeSamples(t *testing.T) {
+	endpoints := map[string][]byte{
+		"stat/device": testExporterEndpoints["stat/device"],
+		"stat/sta":    testExporterEndpoints["stat/sta"],
+		"stat/guest":  testExporterEndpoints["stat/guest"],
+		"stat/health": testExporterEndpoints["stat/health"],
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	e, err := New(testExporterSites(2), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	// Count each sample in the output, other than the series and samples
+	// counts themselves
+	count := func(out []byte) int {
+		var n int
+		for _, l := range strings.Split(string(out), "\n") {
+			if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "unifi_exporter_series_total") || strings.HasPrefix(l, "unifi_exporter_scrape_samples_scraped") {
+				continue
+			}
+
+			n++
+		}
+
+		return n
+	}
+
+	out := testCollector(t, e)
+	want := count(out)
+
+	m := regexp.MustCompile(fmt.Sprintf(`unifi_exporter_scrape_samples_scraped %d\n`, want))
+	if !m.Match(out) {
+		t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+	}
+
+	// A failed scrape reports the samples of the last successful scrape
+	endpoints["stat/device"] = []byte(`{`)
+	out = testCollector(t, e)
+
+	if count(out) == want {
+		t.Fatal("failed scrape unexpectedly emitted the same number of samples")
+	}
+	if !m.Match(out) {
+		t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+	}
+}
+
+func TestExporterUniqueStations(t *testing.T) {
+	// Every site reports the same station, which must only be counted once
+	c, done := testUniFiClientEndpoints(t, testExporterEndpoints)
+	defer done()
+
+	var tests = []struct {
+		desc string
+		cfg  *Config
+	}{
+		{
+			desc: "one shard",
+		},
+		{
+			desc: "shard by site",
+			cfg:  &Config{ShardBySite: true},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		e, err := New(testExporterSites(2), func() (*unifi.Client, error) {
+			return c, nil
+		}, tt.cfg)
+		if err != nil {
+			t.Fatalf("failed to create exporter: %v", err)
+		}
+
+		matches := []*regexp.Regexp{
+			regexp.MustCompile(`unifi_stations{site="Site 0"} 1\n`),
+			regexp.MustCompile(`unifi_stations{site="Site 1"} 1\n`),
+			regexp.MustCompile(`unifi_controller_unique_stations 1\n`),
+		}
+
+		// Stations seen in one scrape are not carried over to the next
+		for j := 0; j < 2; j++ {
+			out := testCollector(t, e)
+
+			for _, m := range matches {
+				if !m.Match(out) {
+					t.Fatalf("[%02d] output failed to match regex: %s\n%s", j, m, string(out))
+				}
+			}
+		}
+	}
+}
+
+func TestSamples(t *testing.T) {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test",
+		Help:    "test",
+		Buckets: []float64{1, 2, 3},
+	})
+	s := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "test",
+		Help:       "test",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01},
+	})
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "test",
+		Help: "test",
+	})
+
+	tests := []struct {
+		name string
+		m    prometheus.Metric
+		want int
+	}{
+		{
+			// Three buckets, +Inf, sum, and count
+			name: "histogram",
+			m:    h,
+			want: 6,
+		},
+		{
+			// Two quantiles, sum, and count
+			name: "summary",
+			m:    s,
+			want: 4,
+		},
+		{
+			name: "gauge",
+			m:    g,
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dm dto.Metric
+			if err := tt.m.Write(&dm); err != nil {
+				t.Fatalf("failed to write metric: %v", err)
+			}
+
+			if got := samples(&dm); tt.want != got {
+				t.Fatalf("unexpected number of samples:\n- want: %d\n-  got: %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExporterController(t *testing.T) {
+	sites := []*unifi.Site{{
+		Name:        "default",
+		Description: "Default",
+	}}
+
+	// Each controller has a site with the same description, so only the
+	// controller and site name labels distinguish their series
+	collect := func(controller string) []byte {
+		c, done := testUniFiClientEndpoints(t, testExporterEndpoints)
+		defer done()
+
+		clientFn := func() (*unifi.Client, error) {
+			return c, nil
+		}
+
+		e, err := New(sites, clientFn, &Config{Controller: controller})
+		if err != nil {
+			t.Fatalf("failed to create exporter: %v", err)
+		}
+
+		return testCollector(t, e)
+	}
+
+	series := make(map[string]bool)
+	for _, controller := range []string{"unifi1", "unifi2"} {
+		out := collect(controller)
+
+		matches := []*regexp.Regexp{
+			regexp.MustCompile(fmt.Sprintf(`unifi_devices{controller="%s",site="Default",site_name="default"} 1`, controller)),
+			regexp.MustCompile(fmt.Sprintf(`unifi_stations{controller="%s",site="Default",site_name="default"} 1`, controller)),
+		}
+
+		for _, m := range matches {
+			if !m.Match(out) {
+				t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+			}
+		}
+
+		for _, l := range strings.Split(string(out), "\n") {
+			if !strings.HasPrefix(l, "unifi_devices{") && !strings.HasPrefix(l, "unifi_stations{") {
+				continue
+			}
+
+			// Strip the sample value to compare only series identity
+			id := l[:strings.LastIndex(l, " ")]
+			if series[id] {
+				t.Fatalf("duplicate series across controllers: %s", id)
+			}
+			series[id] = true
+		}
+	}
+
+	if l := len(series); l != 4 {
+		t.Fatalf("unexpected number of distinct series: %d", l)
+	}
+}
+
+func TestNewInvalidStationLabel(t *testing.T) {
+	c, done := testUniFiClient(t, []byte(`{"data":[]}`))
+	defer done()
+
+	clientFn := func() (*unifi.Client, error) {
+		return c, nil
+	}
+
+	_, err := New(nil, clientFn, &Config{StationLabel: "foo"})
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	c, done := testUniFiClient(t, []byte(`{"meta":{"rc":"error","msg":"api.err.NoSiteContext"},"data":[]}`))
+	defer done()
+
+	_, err := c.Devices("default")
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	aerr, ok := err.(*unifi.APIError)
+	if !ok {
+		t.Fatalf("unexpected error type: %T: %v", err, err)
+	}
+
+	if want, got := "api.err.NoSiteContext", aerr.Message; want != got {
+		t.Fatalf("unexpected error message:\n- want: %v\n-  got: %v", want, got)
+	}
+
+	// Responses with an "ok" envelope are not errors
+	c, done = testUniFiClient(t, []byte(`{"meta":{"rc":"ok"},"data":[]}`))
+	defer done()
+
+	if _, err := c.Devices("default"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClientMaxResponseSize(t *testing.T) {
+	input := []byte(`{"data":[]}`)
+
+	c, done := testUniFiClient(t, input)
+	defer done()
+
+	// A body exactly at the limit is permitted
+	c.MaxResponseSize = int64(len(input))
+	if _, err := c.Devices("default"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c.MaxResponseSize = int64(len(input)) - 1
+	_, err := c.Devices("default")
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	if want, got := "response body exceeds maximum size of 10 bytes", err.Error(); want != got {
+		t.Fatalf("unexpected error:\n- want: %v\n-  got: %v", want, got)
+	}
+}
+
+func TestClientContextCanceled(t *testing.T) {
+	// Block each request until the test completes
+	received := make(chan struct{}, 3)
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{
+			name: "devices",
+			fn: func(ctx context.Context) error {
+				_, err := c.DevicesContext(ctx, "default")
+				return err
+			},
+		},
+		{
+			name: "stations",
+			fn: func(ctx context.Context) error {
+				_, err := c.StationsContext(ctx, "default")
+				return err
+			},
+		},
+		{
+			name: "sites",
+			fn: func(ctx context.Context) error {
+				_, err := c.SitesContext(ctx)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			// Cancel the context once the request is in flight
+			go func() {
+				<-received
+				cancel()
+			}()
+
+			if err := tt.fn(ctx); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+		})
+	}
+}
+
+func TestCollectorsScrapeTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	cfg := &Config{
+		ScrapeTimeout: 50 * time.Millisecond,
+	}
+	sites := testExporterSites(1)
+
+	var tests = []struct {
+		desc string
+		c    collector
+	}{
+		{
+			desc: "alarms",
+			c:    NewAlarmCollector(c, sites, cfg),
+		},
+		{
+			desc: "devices",
+			c:    NewDeviceCollector(c, sites, cfg),
+		},
+		{
+			desc: "gateways",
+			c:    NewGatewayCollector(c, sites, cfg),
+		},
+		{
+			desc: "guests",
+			c:    NewGuestCollector(c, sites, cfg),
+		},
+		{
+			desc: "stations",
+			c:    NewStationCollector(c, sites, cfg),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		start := time.Now()
+		if err := tt.c.CollectError(make(chan prometheus.Metric, 64)); err == nil {
+			t.Fatal("expected an error, but none occurred")
+		}
+
+		// The request is canceled well before the HTTP client's own timeout
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("collection took %v, expected scrape timeout to cancel it", elapsed)
+		}
+	}
+}
+
+func TestExporterSitesUnavailable(t *testing.T) {
+	endpoints := map[string][]byte{
+		"self/sites": []byte(`{`),
+	}
+	for k, v := range testExporterEndpoints {
+		endpoints[k] = v
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	e, err := New(testExporterSites(1), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	// Only the device count mismatch depends on the site list, so the
+	// collection should otherwise succeed
+	out := testCollector(t, e)
+	if want := []byte("unifi_up 1\n"); !bytes.Contains(out, want) {
+		t.Fatalf("output missing %q:\n%s", string(want), string(out))
+	}
+	if bytes.Contains(out, []byte("unifi_site_device_count_mismatch")) {
+		t.Fatalf("unexpected device count mismatch series:\n%s", string(out))
+	}
+}
+
+func TestExporterSiteSummaryRefreshed(t *testing.T) {
+	endpoints := map[string][]byte{
+		"self/sites": []byte(`{"data":[{"name":"site0","desc":"Site 0","num_ap":2,"num_sta":10,"role":"readonly"}]}`),
+	}
+	for k, v := range testExporterEndpoints {
+		endpoints[k] = v
+	}
+
+	c, done := testUniFiClientEndpoints(t, endpoints)
+	defer done()
+
+	// The sites the Exporter is created with report no access points or
+	// stations, but the site list retrieved for each collection does
+	sites := testExporterSites(1)
+	sites[0].Role = "admin"
+
+	e, err := New(sites, func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	out := testCollector(t, e)
+
+	for _, want := range []string{
+		`unifi_site_access_points{role="readonly",site="Site 0"} 2`,
+		`unifi_site_stations{role="readonly",site="Site 0"} 10`,
+		`unifi_site_role_info{role="readonly",site="Site 0"} 1`,
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Fatalf("output missing %q:\n%s", want, string(out))
+		}
+	}
+}
+
+func TestExporterControllerTimezone(t *testing.T) {
+	c, done := testUniFiClientEndpoints(t, map[string][]byte{
+		"stat/sysinfo": []byte(`{"data":[{"hostname":"unifi","timezone":"America/New_York","version":"5.6.22"}]}`),
+	})
+	defer done()
+
+	e, err := New(testExporterSites(2), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	out := testCollector(t, e)
+
+	// The timezone is controller-wide, and is reported once without a site
+	want := []byte("unifi_controller_timezone_info{timezone=\"America/New_York\"} 1\n")
+	if !bytes.Contains(out, want) {
+		t.Fatalf("output missing controller timezone:\n%s", string(out))
+	}
+}
+
+func TestClientMaxConcurrentRequests(t *testing.T) {
+	const n = 4
+
+	// Hold each request until all n requests are in flight
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/stat/device") {
+			wg.Done()
+			wg.Wait()
+		}
+
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	current := c
+	e, err := New(nil, func() (*unifi.Client, error) {
+		return current, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	var done sync.WaitGroup
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			if _, err := c.Devices("default"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	done.Wait()
+
+	if want, got := int64(n), c.MaxConcurrentRequests(); want != got {
+		t.Fatalf("unexpected concurrent requests high-water mark:\n- want: %d\n-  got: %d", want, got)
+	}
+
+	// The high-water mark is retained when the client is replaced
+	current, err = unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+	if err := e.initClient(); err != nil {
+		t.Fatalf("failed to initialize client: %v", err)
+	}
+
+	out := testCollector(t, e)
+	if want := []byte("unifi_controller_concurrent_requests_max 4\n"); !bytes.Contains(out, want) {
+		t.Fatalf("output missing concurrent requests high-water mark:\n%s", string(out))
+	}
+}
+
+func TestCollectorsSiteConcurrency(t *testing.T) {
+	const (
+		delay    = 50 * time.Millisecond
+		numSites = 4
+	)
+
+	// Each request is delayed, so that collecting sites serially takes
+	// noticeably longer than collecting them concurrently
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+		for k, v := range testExporterEndpoints {
+			if strings.HasSuffix(r.URL.Path, k) {
+				_, _ = w.Write(v)
+				return
+			}
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	sites := testExporterSites(numSites)
+
+	tests := []struct {
+		name string
+		// requests is the number of requests the collector makes per site
+		requests  int
+		collector prometheus.Collector
+	}{
+		{
+			name:      "devices",
+			requests:  2,
+			collector: NewDeviceCollector(c, sites, nil),
+		},
+		{
+			name:      "stations",
+			requests:  3,
+			collector: NewStationCollector(c, sites, nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			out := testCollector(t, tt.collector)
+			elapsed := time.Since(start)
+
+			if serial := time.Duration(numSites*tt.requests) * delay; elapsed >= serial {
+				t.Fatalf("collection took %v, expected less than serial time %v", elapsed, serial)
+			}
+
+			for _, s := range sites {
+				if want := fmt.Sprintf("site=%q", s.Description); !bytes.Contains(out, []byte(want)) {
+					t.Fatalf("output missing metrics for site %q:\n%s", s.Description, string(out))
+				}
+			}
+		})
+	}
+}
+
+func TestForEachSiteError(t *testing.T) {
+	sites := testExporterSites(4)
+	desc := prometheus.NewDesc("test", "test", nil, nil)
+
+	got, err := forEachSite(sites, 2, func(s *unifi.Site) (*prometheus.Desc, error) {
+		if s.Name == "site1" || s.Name == "site3" {
+			return desc, &siteError{site: s, err: errors.New("failed")}
+		}
+
+		return nil, nil
+	})
+	if got != desc {
+		t.Fatalf("unexpected descriptor: %v", got)
+	}
+
+	// Every failing site is reported, in site order
+	serrs, ok := err.(siteErrors)
+	if !ok || len(serrs) != 2 || serrs[0].site.Name != "site1" || serrs[1].site.Name != "site3" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFailedSites(t *testing.T) {
+	sites := testExporterSites(3)
+
+	var tests = []struct {
+		desc string
+		err  error
+		want []string
+	}{
+		{
+			desc: "one site",
+			err:  &siteError{site: sites[1], err: errors.New("failed")},
+			want: []string{"Site 1"},
+		},
+		{
+			desc: "multiple sites",
+			err: siteErrors{
+				{site: sites[0], err: errors.New("failed")},
+				{site: sites[2], err: errors.New("failed")},
+			},
+			want: []string{"Site 0", "Site 2"},
+		},
+		{
+			desc: "not attributed to a site",
+			err:  errors.New("failed"),
+			want: []string{"Site 0", "Site 1", "Site 2"},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Logf("[%02d] test %q", i, tt.desc)
+
+		if want, got := tt.want, failedSites(sites, tt.err); !reflect.DeepEqual(want, got) {
+			t.Fatalf("unexpected failed sites:\n- want: %v\n-  got: %v", want, got)
+		}
+	}
+}
+
+func TestExporterSiteScrapeSuccessRatioMultipleFailures(t *testing.T) {
+	// Requests fail for two of three sites, both of which must be recorded
+	// as failed
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if strings.Contains(r.URL.Path, "/s/site1/") || strings.Contains(r.URL.Path, "/s/site2/") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer unifiServer.Close()
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	e, err := New(testExporterSites(3), func() (*unifi.Client, error) {
+		return c, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	out := testCollector(t, e)
+
+	matches := []*regexp.Regexp{
+		regexp.MustCompile(`unifi_site_scrape_success_ratio{site="Site 0"} 1\n`),
+		regexp.MustCompile(`unifi_site_scrape_success_ratio{site="Site 1"} 0\n`),
+		regexp.MustCompile(`unifi_site_scrape_success_ratio{site="Site 2"} 0\n`),
+	}
+
+	for _, m := range matches {
+		if !m.Match(out) {
+			t.Fatalf("output failed to match regex: %s\n%s", m, string(out))
+		}
+	}
+}
+
 func testUniFiClient(t *testing.T, input []byte) (*unifi.Client, func()) {
 	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
@@ -24,25 +1595,53 @@ func testUniFiClient(t *testing.T, input []byte) (*unifi.Client, func()) {
 	return c, func() { unifiServer.Close() }
 }
 
+// testUniFiClientEndpoints is like testUniFiClient, but serves a different
+// response for each API endpoint whose path ends with a key in endpoints.
+// Endpoints which are not found return an empty data array.
+func testUniFiClientEndpoints(t *testing.T, endpoints map[string][]byte) (*unifi.Client, func()) {
+	unifiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+		for k, v := range endpoints {
+			if strings.HasSuffix(r.URL.Path, k) && len(v) > 0 {
+				_, _ = w.Write(v)
+				return
+			}
+		}
+
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+
+	c, err := unifi.NewClient(unifiServer.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create UniFi client: %v", err)
+	}
+
+	return c, func() { unifiServer.Close() }
+}
+
 func testCollector(t *testing.T, collector prometheus.Collector) []byte {
-	if err := prometheus.Register(collector); err != nil {
+	// Use a fresh registry for each collection, so that collectors with
+	// differing label sets for the same metric may be tested
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
 		t.Fatalf("failed to register Prometheus collector: %v", err)
 	}
-	defer prometheus.Unregister(collector)
-
-	promServer := httptest.NewServer(prometheus.Handler())
-	defer promServer.Close()
 
-	resp, err := http.Get(promServer.URL)
+	mfs, err := reg.Gather()
 	if err != nil {
-		t.Fatalf("failed to GET data from prometheus: %v", err)
+		// Like the Prometheus HTTP handler, expose only the error when
+		// collection fails
+		return []byte(err.Error())
 	}
-	defer resp.Body.Close()
 
-	buf, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("failed to read server response: %v", err)
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			t.Fatalf("failed to encode metric family: %v", err)
+		}
 	}
 
-	return buf
+	return buf.Bytes()
 }